@@ -0,0 +1,208 @@
+package daemon
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cocosip/utils/buildinfo"
+	"github.com/kardianos/service"
+)
+
+// Exit codes returned by Controller.Execute. The status command follows the LSB init script conventions.
+const (
+	ExitOK         = 0 // ExitOK means the command succeeded, or the service is running.
+	ExitFailure    = 1 // ExitFailure means the command failed.
+	ExitUsage      = 2 // ExitUsage means the command line is invalid.
+	ExitNotRunning = 3 // ExitNotRunning means the service is stopped or not installed.
+	ExitUnknown    = 4 // ExitUnknown means the status of the service can't be determined.
+)
+
+// controller is the part of Controller used by the command-line dispatcher.
+type controller interface {
+	Install() error
+	Uninstall() error
+	Start() error
+	Stop() error
+	Restart() error
+	Reload() error
+	Run() error
+	Status() (service.Status, error)
+	PID() (pid int, running bool, err error)
+}
+
+// command is a subcommand of the command-line dispatcher.
+type command struct {
+	name  string
+	usage string
+	// define defines the flags of the command and returns the function running it once they are parsed.
+	define func(flags *flag.FlagSet) func(c *cli) int
+}
+
+// commands lists the subcommands in the order they are shown in the usage.
+var commands = []command{
+	{"install", "install the service", action("install", "installed", controller.Install)},
+	{"uninstall", "stop and uninstall the service", action("uninstall", "uninstalled", controller.Uninstall)},
+	{"start", "start the installed service", action("start", "started", controller.Start)},
+	{"stop", "stop the running service", action("stop", "stopped", controller.Stop)},
+	{"restart", "restart the installed service", action("restart", "restarted", controller.Restart)},
+	{"reload", "reload the configuration of the running service", action("reload", "reloaded", controller.Reload)},
+	{"run", "run the service in the foreground (the default)", noFlags((*cli).run)},
+	{"status", "show the status of the service", noFlags((*cli).status)},
+	{"version", "show the build information", versionCommand},
+}
+
+// cli dispatches a command line to a controller.
+type cli struct {
+	ctrl   controller
+	name   string    // 服务名称, 用于输出
+	stdout io.Writer // 标准输出
+	stderr io.Writer // 错误输出
+}
+
+// Execute runs the command given by args, typically os.Args[1:], and returns the exit code of the process:
+//
+//	install    install the service
+//	uninstall  stop and uninstall the service
+//	start      start the installed service
+//	stop       stop the running service
+//	restart    restart the installed service
+//	reload     reload the configuration of the running service (SIGHUP)
+//	run        run the service in the foreground
+//	status     show the status of the service
+//	version    show the build information; -json prints it as JSON
+//
+// Without arguments, the service is run, which is how the service manager starts an installed service
+// unless service.Config.Arguments is set. Results are printed to stdout and errors to stderr.
+// A typical main function ends with os.Exit(controller.Execute(os.Args[1:])).
+func (d *Controller) Execute(args []string) int {
+	c := &cli{ctrl: d, name: d.service.String(), stdout: os.Stdout, stderr: os.Stderr}
+	return c.execute(args)
+}
+
+// execute runs the command given by args and returns the exit code.
+func (c *cli) execute(args []string) int {
+	name := "run"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	switch name {
+	case "help", "-h", "-help", "--help":
+		c.usage(c.stdout)
+		return ExitOK
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		flags.SetOutput(c.stderr)
+		run := cmd.define(flags)
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return ExitOK
+			}
+			return ExitUsage
+		}
+		if flags.NArg() > 0 {
+			fmt.Fprintf(c.stderr, "%s: unexpected arguments: %v\n", cmd.name, flags.Args())
+			return ExitUsage
+		}
+		return run(c)
+	}
+	fmt.Fprintf(c.stderr, "unknown command %q\n", name)
+	c.usage(c.stderr)
+	return ExitUsage
+}
+
+// usage prints the list of commands to w.
+func (c *cli) usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", c.name)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+// noFlags returns the definition of a command without flags running fn.
+func noFlags(fn func(c *cli) int) func(flags *flag.FlagSet) func(c *cli) int {
+	return func(*flag.FlagSet) func(c *cli) int { return fn }
+}
+
+// action returns the definition of a command calling fn on the controller and reporting the result.
+func action(verb, done string, fn func(controller) error) func(flags *flag.FlagSet) func(c *cli) int {
+	return noFlags(func(c *cli) int {
+		if err := fn(c.ctrl); err != nil {
+			fmt.Fprintf(c.stderr, "Failed to %s %s: %v\n", verb, c.name, err)
+			return ExitFailure
+		}
+		fmt.Fprintf(c.stdout, "Service %s %s.\n", c.name, done)
+		return ExitOK
+	})
+}
+
+// versionCommand defines the -json flag of the version command.
+func versionCommand(flags *flag.FlagSet) func(c *cli) int {
+	asJSON := flags.Bool("json", false, "print the build information as JSON")
+	return func(c *cli) int { return c.version(*asJSON) }
+}
+
+// run runs the service in the foreground until it is stopped.
+func (c *cli) run() int {
+	if err := c.ctrl.Run(); err != nil {
+		fmt.Fprintf(c.stderr, "Failed to run %s: %v\n", c.name, err)
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+// status prints the status of the service reported by the service manager and, if a PID file
+// is configured, of the daemon process. A daemon running in the foreground counts as running.
+func (c *cli) status() int {
+	if pid, running, err := c.ctrl.PID(); err == nil {
+		if running {
+			fmt.Fprintf(c.stdout, "Process %d is running.\n", pid)
+			return ExitOK
+		}
+		if pid != 0 {
+			fmt.Fprintf(c.stdout, "Process %d is not running, the PID file is stale.\n", pid)
+		}
+	}
+	status, err := c.ctrl.Status()
+	switch {
+	case errors.Is(err, service.ErrNotInstalled):
+		fmt.Fprintf(c.stdout, "Service %s is not installed.\n", c.name)
+		return ExitNotRunning
+	case err != nil:
+		fmt.Fprintf(c.stderr, "Failed to query status of %s: %v\n", c.name, err)
+		return ExitUnknown
+	}
+	switch status {
+	case service.StatusRunning:
+		fmt.Fprintf(c.stdout, "Service %s is running.\n", c.name)
+		return ExitOK
+	case service.StatusStopped:
+		fmt.Fprintf(c.stdout, "Service %s is stopped.\n", c.name)
+		return ExitNotRunning
+	default:
+		fmt.Fprintf(c.stdout, "Service %s status is unknown.\n", c.name)
+		return ExitUnknown
+	}
+}
+
+// version prints the build information, as JSON if asJSON is set.
+func (c *cli) version(asJSON bool) int {
+	info := buildinfo.Get()
+	if !asJSON {
+		fmt.Fprintln(c.stdout, info.String())
+		return ExitOK
+	}
+	data, err := info.JSON()
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return ExitFailure
+	}
+	fmt.Fprintln(c.stdout, data)
+	return ExitOK
+}
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/cocosip/utils/buildinfo"
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeController records the calls of the command-line dispatcher.
+type fakeController struct {
+	calls   []string
+	err     error
+	status  service.Status
+	pid     int
+	running bool
+}
+
+func (f *fakeController) call(name string) error {
+	f.calls = append(f.calls, name)
+	return f.err
+}
+
+func (f *fakeController) Install() error   { return f.call("install") }
+func (f *fakeController) Uninstall() error { return f.call("uninstall") }
+func (f *fakeController) Start() error     { return f.call("start") }
+func (f *fakeController) Stop() error      { return f.call("stop") }
+func (f *fakeController) Restart() error   { return f.call("restart") }
+func (f *fakeController) Reload() error    { return f.call("reload") }
+func (f *fakeController) Run() error       { return f.call("run") }
+func (f *fakeController) Status() (service.Status, error) {
+	return f.status, f.call("status")
+}
+func (f *fakeController) PID() (int, bool, error) {
+	if f.pid == 0 {
+		return 0, false, errors.New("no PID file configured")
+	}
+	return f.pid, f.running, nil
+}
+
+func newTestCLI(ctrl controller) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &cli{ctrl: ctrl, name: "test", stdout: stdout, stderr: stderr}, stdout, stderr
+}
+
+func TestCLI_Actions(t *testing.T) {
+	ctrl := &fakeController{}
+	c, stdout, _ := newTestCLI(ctrl)
+	for _, name := range []string{"install", "start", "stop", "restart", "reload", "uninstall", "run"} {
+		assert.Equal(t, ExitOK, c.execute([]string{name}))
+	}
+	// Without arguments, the service is run.
+	assert.Equal(t, ExitOK, c.execute(nil))
+	assert.Equal(t, []string{"install", "start", "stop", "restart", "reload", "uninstall", "run", "run"}, ctrl.calls)
+	assert.Contains(t, stdout.String(), "Service test installed.\n")
+
+	ctrl.err = errors.New("access denied")
+	c, _, stderr := newTestCLI(ctrl)
+	assert.Equal(t, ExitFailure, c.execute([]string{"install"}))
+	assert.Equal(t, "Failed to install test: access denied\n", stderr.String())
+}
+
+func TestCLI_Usage(t *testing.T) {
+	c, stdout, stderr := newTestCLI(&fakeController{})
+	assert.Equal(t, ExitUsage, c.execute([]string{"deploy"}))
+	assert.Contains(t, stderr.String(), `unknown command "deploy"`)
+	assert.Contains(t, stderr.String(), "Usage: test <command> [flags]")
+
+	assert.Equal(t, ExitUsage, c.execute([]string{"start", "now"}))
+	assert.Equal(t, ExitUsage, c.execute([]string{"start", "-force"}))
+
+	assert.Equal(t, ExitOK, c.execute([]string{"help"}))
+	assert.Contains(t, stdout.String(), "uninstall")
+	assert.Equal(t, ExitOK, c.execute([]string{"version", "-h"}))
+}
+
+func TestCLI_Status(t *testing.T) {
+	tests := []struct {
+		status service.Status
+		err    error
+		code   int
+		output string
+	}{
+		{service.StatusRunning, nil, ExitOK, "is running"},
+		{service.StatusStopped, nil, ExitNotRunning, "is stopped"},
+		{service.StatusUnknown, service.ErrNotInstalled, ExitNotRunning, "is not installed"},
+		{service.StatusUnknown, errors.New("dbus unavailable"), ExitUnknown, "dbus unavailable"},
+	}
+	for _, tt := range tests {
+		c, stdout, stderr := newTestCLI(&fakeController{status: tt.status, err: tt.err})
+		assert.Equal(t, tt.code, c.execute([]string{"status"}))
+		assert.Contains(t, stdout.String()+stderr.String(), tt.output)
+	}
+}
+
+func TestCLI_StatusPID(t *testing.T) {
+	// A daemon running in the foreground is found through its PID file.
+	c, stdout, _ := newTestCLI(&fakeController{status: service.StatusUnknown, err: service.ErrNotInstalled, pid: 42, running: true})
+	assert.Equal(t, ExitOK, c.execute([]string{"status"}))
+	assert.Equal(t, "Process 42 is running.\n", stdout.String())
+
+	c, stdout, _ = newTestCLI(&fakeController{status: service.StatusStopped, pid: 42})
+	assert.Equal(t, ExitNotRunning, c.execute([]string{"status"}))
+	assert.Equal(t, "Process 42 is not running, the PID file is stale.\nService test is stopped.\n", stdout.String())
+}
+
+func TestCLI_Version(t *testing.T) {
+	c, stdout, _ := newTestCLI(&fakeController{})
+	assert.Equal(t, ExitOK, c.execute([]string{"version"}))
+	assert.Equal(t, buildinfo.Get().String()+"\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, ExitOK, c.execute([]string{"version", "-json"}))
+	var info buildinfo.BuildInfo
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &info))
+	assert.Equal(t, buildinfo.Get(), info)
+}
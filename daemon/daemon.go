@@ -3,110 +3,535 @@
 package daemon
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kardianos/service"
 )
 
+// DefaultStopTimeout is the default time Stop waits for the services to return.
+var DefaultStopTimeout = 30 * time.Second
+
 // Service defines the interface that any service managed by the Daemon must implement.
 type Service interface {
 	// Name returns the unique name of the service.
 	Name() string
-	// Run contains the main logic of the service. It should block until the service is stopped
-	// or an unrecoverable error occurs. It should return an error if the service fails to start or encounters a critical issue.
-	Run() error
+	// Run contains the main logic of the service. It should block until ctx is cancelled
+	// or an unrecoverable error occurs. ctx is cancelled when the daemon stops; the service should then
+	// drain its work and return. It should return an error if the service fails to start or encounters a critical issue.
+	Run(ctx context.Context) error
 	// HandleError is called when an error occurs within the service's Run method.
 	// Implementations can use this to log the error, attempt recovery, or signal a shutdown.
 	HandleError(error)
 }
 
+// Shutdowner is implemented by services that need more than the cancellation of the Run context to stop,
+// such as an HTTP server that must stop accepting connections. Shutdown is called when the daemon stops,
+// concurrently with the cancellation, and ctx expires with the stop timeout.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Option is a function type used to configure a Daemon.
+type Option func(d *Daemon)
+
+// WithStopTimeout returns an Option that sets how long Stop waits for the services to return.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(d *Daemon) {
+		d.stopTimeout = timeout
+	}
+}
+
+// WithRestart returns an Option that sets the restart configuration of the service with the given name.
+func WithRestart(name string, config RestartConfig) Option {
+	return func(d *Daemon) {
+		d.restarts[name] = config
+	}
+}
+
+// WithDefaultRestart returns an Option that sets the restart configuration of the services
+// without one set by WithRestart. By default, services are not restarted.
+func WithDefaultRestart(config RestartConfig) Option {
+	return func(d *Daemon) {
+		d.defaultRestart = config
+	}
+}
+
+// WithEscalation returns an Option that sets a function called when a service is given up on:
+// it exceeded the restart limit of its RestartConfig, or it is critical and failed with RestartNever.
+// err is the last error returned by the service. The function is called before a critical
+// service stops the daemon, and can be used to raise an alert.
+func WithEscalation(escalation func(name string, err error)) Option {
+	return func(d *Daemon) {
+		d.escalation = escalation
+	}
+}
+
+// WithHealthTimeout returns an Option that sets the timeout of the health checks run by Handler.
+func WithHealthTimeout(timeout time.Duration) Option {
+	return func(d *Daemon) {
+		d.healthTimeout = timeout
+	}
+}
+
+// WithNotifier returns an Option that sets the Notifier used to notify systemd of the daemon's state.
+// By default, the notifier returned by NotifierFromEnv is used; pass nil to disable notifications.
+func WithNotifier(notifier *Notifier) Option {
+	return func(d *Daemon) {
+		d.notifier = notifier
+	}
+}
+
+// WithWatchdog returns an Option that sets the interval at which the systemd watchdog is notified
+// while the daemon is healthy. By default, the interval returned by WatchdogInterval is used; 0 disables it.
+func WithWatchdog(interval time.Duration) Option {
+	return func(d *Daemon) {
+		d.watchdog = interval
+	}
+}
+
+// WithSystemdUnit returns an Option that sets the systemd unit settings merged into the service
+// configuration by NewControllerWithOptions on linux-systemd, replacing DefaultSystemdUnit.
+func WithSystemdUnit(unit SystemdUnit) Option {
+	return func(d *Daemon) {
+		d.systemd = unit
+	}
+}
+
+// WithPIDFile returns an Option that makes the daemon hold the PID file at path while it runs.
+// Start fails with ErrAlreadyRunning if another instance holds it. See AcquirePIDFile.
+func WithPIDFile(path string) Option {
+	return func(d *Daemon) {
+		d.pidPath = path
+	}
+}
+
 // Daemon is the core structure that manages multiple services.
 // It implements the 'service.Service' interface from the kardianos/service package.
 type Daemon struct {
-	config   *service.Config // config holds the service configuration.
-	services []Service       // services is a slice of individual services to be managed.
-	errs     chan error      // errs is a channel to receive errors from running services.
+	config      *service.Config // config holds the service configuration.
+	services    []Service       // services is a slice of individual services to be managed.
+	order       []Service       // order holds the services sorted by their dependencies.
+	err         error           // err is the error found in the dependencies of the services, returned by Start.
+	stopTimeout time.Duration   // stopTimeout is how long Stop waits for the services to return.
+
+	restarts       map[string]RestartConfig     // restarts holds the restart configuration of each service by name.
+	defaultRestart RestartConfig                // defaultRestart applies to the services not in restarts.
+	escalation     func(name string, err error) // escalation is called when a service is given up on.
+	exit           func(code int)               // exit terminates the process after a critical service failed.
+
+	status        *statusTracker // status records the state of each service.
+	healthTimeout time.Duration  // healthTimeout is the timeout of the health checks run by Handler.
+	notifier      *Notifier      // notifier notifies systemd of the daemon's state.
+	watchdog      time.Duration  // watchdog is the interval at which the systemd watchdog is notified.
+	systemd       SystemdUnit    // systemd holds the settings of the systemd unit.
+	reloadSignal  bool           // reloadSignal enables reloading the services on SIGHUP.
+	pidPath       string         // pidPath is the path of the PID file; empty if not used.
+
+	reloadMu sync.Mutex // reloadMu serializes reloads.
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc // cancel cancels the context of the running services; nil if not running.
+	runners []*runner          // runners track the running services.
+	pidFile *PIDFile           // pidFile is the PID file held while running.
+	ready   atomic.Bool        // ready is set once systemd has been told that all services are ready.
+}
+
+// runner tracks a running service.
+type runner struct {
+	svc       Service
+	deps      []*runner          // deps are the runners of the services svc depends on.
+	cancel    context.CancelFunc // cancel cancels the context of the service.
+	ready     chan struct{}      // ready is closed when the service is ready.
+	readyOnce sync.Once
+	failed    chan struct{} // failed is closed when the service was given up on.
+	failErr   error         // failErr is the error the service failed with, set before failed is closed.
+	done      chan struct{} // done is closed when Run has returned.
+}
+
+// markReady marks the service as ready, starting its dependents.
+func (r *runner) markReady() {
+	r.readyOnce.Do(func() {
+		close(r.ready)
+	})
+}
+
+// markFailed marks the service as given up on with err, failing its dependents that are still waiting for it.
+func (r *runner) markFailed(err error) {
+	r.failErr = err
+	close(r.failed)
 }
 
 // NewDaemon creates and returns a new Daemon instance.
 // It initializes the daemon with a service configuration and a list of services to run.
+// If the dependencies of the services are invalid, Start returns the error;
+// use NewDaemonWithOptions to detect it at construction.
 func NewDaemon(conf *service.Config, services ...Service) *Daemon {
-	return &Daemon{
-		config:   conf,
-		errs:     make(chan error, 100),
-		services: services,
+	d, err := NewDaemonWithOptions(conf, services)
+	if err != nil {
+		d = newDaemon(conf, services)
+		d.err = err
+	}
+	return d
+}
+
+// NewDaemonWithOptions creates and returns a new Daemon instance running services,
+// configured by the optional Option functions.
+// It returns an error if the service names are not unique, or the dependencies declared by services
+// implementing Dependent refer to unknown services or form a cycle.
+func NewDaemonWithOptions(conf *service.Config, services []Service, opts ...Option) (*Daemon, error) {
+	order, err := sortServices(services)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service dependencies: %w", err)
 	}
+	d := newDaemon(conf, services, opts...)
+	d.order = order
+	return d, nil
+}
+
+// newDaemon creates a Daemon running services in the given order.
+func newDaemon(conf *service.Config, services []Service, opts ...Option) *Daemon {
+	d := &Daemon{
+		config:      conf,
+		services:    services,
+		stopTimeout: DefaultStopTimeout,
+		restarts:    make(map[string]RestartConfig),
+		order:       services,
+		exit:        os.Exit,
+
+		status:        newStatusTracker(services),
+		healthTimeout: DefaultHealthTimeout,
+		notifier:      NotifierFromEnv(),
+		watchdog:      WatchdogInterval(),
+		systemd:       DefaultSystemdUnit,
+		reloadSignal:  true,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // Start is part of the 'service.Service' interface.
 // It is called by the service manager when the daemon is started.
-// This method launches a goroutine for each managed service and a goroutine to listen for errors.
+// This method launches a goroutine for each managed service, passing it a context that Stop cancels.
+// A service declaring dependencies is only run once they are ready; Start doesn't wait for that.
+// Once all services are ready, READY=1 is sent to systemd and the watchdog loop starts.
+// While the daemon runs, SIGHUP reloads the services implementing Reloader.
+// If a PID file is set with WithPIDFile and another instance holds it, Start returns ErrAlreadyRunning.
 func (d *Daemon) Start(_ service.Service) error {
-	// Start a goroutine to listen for errors from individual services.
-	go d.handleServiceErrors()
-
-	// Start each service in its own goroutine.
-	for _, svc := range d.services {
-		go func(s Service) {
-			slog.Info(fmt.Sprintf("Starting service: %s", s.Name()))
-			if err := s.Run(); err != nil {
-				s.HandleError(err)
-				d.errs <- fmt.Errorf("service %s failed: %w", s.Name(), err)
-			}
-			slog.Info(fmt.Sprintf("Service %s stopped.", s.Name()))
-		}(svc)
+	if d.err != nil {
+		return d.err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancel != nil {
+		return errors.New("daemon is already running")
+	}
+	if d.pidPath != "" {
+		pidFile, err := AcquirePIDFile(d.pidPath)
+		if err != nil {
+			return err
+		}
+		d.pidFile = pidFile
 	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.ready.Store(false)
+	d.status.reset()
+	d.runners = make([]*runner, 0, len(d.order))
+
+	// Start each service in its own goroutine, in dependency order.
+	byName := make(map[string]*runner, len(d.order))
+	for _, svc := range d.order {
+		r := &runner{svc: svc, ready: make(chan struct{}), failed: make(chan struct{}), done: make(chan struct{})}
+		for _, dep := range dependencies(svc) {
+			r.deps = append(r.deps, byName[dep])
+		}
+		byName[svc.Name()] = r
+		d.runners = append(d.runners, r)
+
+		var runCtx context.Context
+		runCtx, r.cancel = context.WithCancel(ctx)
+		go d.run(runCtx, r)
+	}
+	go d.supervise(ctx, d.runners)
+	d.handleReloadSignals(ctx)
 	return nil
 }
 
-// handleServiceErrors listens for errors sent by individual services on the errs channel.
-// It logs these errors. In a more complex scenario, it might trigger service restarts or other recovery actions.
-func (d *Daemon) handleServiceErrors() {
-	for err := range d.errs {
-		slog.Error("Daemon service error", "error", err)
-		// Here, you could add logic to restart a failed service,
-		// or decide to stop the entire daemon if a critical service fails.
+// run runs a service, restarting it according to its RestartConfig, until it is given up on
+// or ctx is cancelled. An error caused by the cancellation of ctx while stopping is not reported.
+func (d *Daemon) run(ctx context.Context, r *runner) {
+	defer close(r.done)
+
+	s := r.svc
+	config := d.restartConfig(s.Name())
+	tracker := &restartTracker{config: config}
+
+	// Wait for the dependencies to be ready.
+	d.status.set(s.Name(), StateStarting, nil)
+	for _, dep := range r.deps {
+		select {
+		case <-dep.ready:
+		case <-dep.failed:
+			select {
+			case <-dep.ready:
+				// The dependency failed after it was ready.
+				continue
+			default:
+			}
+			err := fmt.Errorf("%w: %s: %w", ErrDependencyFailed, dep.svc.Name(), dep.failErr)
+			s.HandleError(err)
+			slog.Error("Daemon service error", "error", fmt.Errorf("service %s failed: %w", s.Name(), err))
+			d.status.set(s.Name(), StateFailed, err)
+			r.markFailed(err)
+			if config.Critical {
+				d.escalate(s.Name(), err, config)
+			}
+			return
+		case <-ctx.Done():
+			d.status.set(s.Name(), StateStopped, nil)
+			return
+		}
+	}
+
+	for {
+		d.status.set(s.Name(), StateStarting, nil)
+		slog.Info(fmt.Sprintf("Starting service: %s", s.Name()))
+		ready := func() {
+			r.markReady()
+			d.status.ready(s.Name())
+		}
+		runCtx := ctx
+		if signalsReady(s) {
+			runCtx = context.WithValue(ctx, readyKey{}, ready)
+		} else {
+			ready()
+		}
+		err := runService(runCtx, s)
+		if err == nil {
+			// A service that completed successfully is ready, even if it didn't signal it.
+			r.markReady()
+		}
+		if err != nil && ctx.Err() != nil && errors.Is(err, context.Canceled) {
+			// The service returned the cancellation of the daemon: it stopped as asked.
+			err = nil
+		}
+		if err != nil {
+			s.HandleError(err)
+			slog.Error("Daemon service error", "error", fmt.Errorf("service %s failed: %w", s.Name(), err))
+		}
+		slog.Info(fmt.Sprintf("Service %s stopped.", s.Name()))
+		if ctx.Err() != nil {
+			d.status.set(s.Name(), StateStopped, err)
+			return
+		}
+
+		if !config.shouldRestart(err) {
+			if err == nil {
+				d.status.set(s.Name(), StateStopped, nil)
+				return
+			}
+			d.status.set(s.Name(), StateFailed, err)
+			r.markFailed(err)
+			if config.Critical {
+				d.escalate(s.Name(), err, config)
+			}
+			return
+		}
+		n, ok := tracker.next(time.Now())
+		if !ok {
+			slog.Error(fmt.Sprintf("Service %s restarted %d times within %s, giving up.", s.Name(), n, config.Window))
+			d.status.set(s.Name(), StateFailed, err)
+			r.markFailed(err)
+			d.escalate(s.Name(), err, config)
+			return
+		}
+		d.status.set(s.Name(), StateBackoff, err)
+
+		delay := config.backoff(n)
+		slog.Warn(fmt.Sprintf("Restarting service %s in %s (restart %d, policy %s).", s.Name(), delay, n, config.Policy))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			d.status.set(s.Name(), StateStopped, nil)
+			return
+		}
+	}
+}
+
+// PanicError is the error reported for a service whose Run method panicked.
+// The panic is recovered, so it only stops the service, which is then restarted according to its RestartConfig.
+type PanicError struct {
+	Service string // Service is the name of the service.
+	Value   any    // Value is the value passed to panic.
+	Stack   []byte // Stack is the stack trace of the goroutine at the time of the panic.
+}
+
+// Error returns the panic value and the stack trace.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("service %s panicked: %v\n%s", e.Service, e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// runService calls the Run method of s, converting a panic into a *PanicError.
+func runService(ctx context.Context, s Service) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Service: s.Name(), Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return s.Run(ctx)
+}
+
+// restartConfig returns the restart configuration of the service with the given name.
+func (d *Daemon) restartConfig(name string) RestartConfig {
+	if config, ok := d.restarts[name]; ok {
+		return config
+	}
+	return d.defaultRestart
+}
+
+// escalate calls the escalation function for a service that was given up on and,
+// if the service is critical, stops the daemon and exits the process with status 1.
+func (d *Daemon) escalate(name string, err error, config RestartConfig) {
+	if d.escalation != nil {
+		d.escalation(name, err)
 	}
+	if !config.Critical {
+		return
+	}
+	slog.Error(fmt.Sprintf("Critical service %s failed, stopping daemon.", name), "error", err)
+	// Stop waits for this service's runner to return, so it runs in its own goroutine.
+	go func() {
+		if err := d.Stop(nil); err != nil {
+			slog.Error("Failed to stop daemon", "error", err)
+		}
+		d.exit(1)
+	}()
 }
 
 // Stop is part of the 'service.Service' interface.
 // It is called by the service manager when the daemon is stopped.
-// This method should perform any necessary cleanup before the daemon exits.
+// It sends STOPPING=1 to systemd, cancels the context of the services, calls Shutdown on those implementing Shutdowner, and waits
+// up to the stop timeout for every service to return. A service is only stopped once the services
+// depending on it have returned. It returns an error naming the services that
+// missed the deadline, together with the errors returned by Shutdown. Finally, the PID file is removed.
 func (d *Daemon) Stop(_ service.Service) error {
-	// Close the error channel to signal handleServiceErrors to stop.
-	close(d.errs)
+	d.mu.Lock()
+	cancel, runners, pidFile := d.cancel, d.runners, d.pidFile
+	d.cancel, d.runners, d.pidFile = nil, nil, nil
+	d.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	d.notify(NotifyStopping)
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), d.stopTimeout)
+	defer cancelTimeout()
+	defer cancel()
 
-	// In a real-world scenario, you might want to gracefully stop each running service here.
-	// For example, by sending a signal to their goroutines or calling a Stop() method on them.
-	// The current implementation relies on the service manager to terminate the process.
+	// Stop the services in reverse dependency order: each one is cancelled once its dependents have stopped,
+	// or the deadline has passed. Independent services stop concurrently.
+	stopped := make(map[*runner]chan struct{}, len(runners))
+	dependents := make(map[*runner][]*runner, len(runners))
+	for _, r := range runners {
+		stopped[r] = make(chan struct{})
+		for _, dep := range r.deps {
+			dependents[dep] = append(dependents[dep], r)
+		}
+	}
+	var mu sync.Mutex
+	var errs []error
+	for _, r := range runners {
+		go func() {
+			defer close(stopped[r])
+			for _, dependent := range dependents[r] {
+				select {
+				case <-stopped[dependent]:
+				case <-ctx.Done():
+				}
+			}
+			r.cancel()
+			if shutdowner, ok := r.svc.(Shutdowner); ok {
+				if err := shutdowner.Shutdown(ctx); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("service %s failed to shut down: %w", r.svc.Name(), err))
+					mu.Unlock()
+				}
+			}
+			<-r.done
+		}()
+	}
 
-	// If running interactively (e.g., from command line), exit the process.
-	// This is typically handled by the service package itself, but added for clarity.
-	if service.Interactive() {
-		slog.Info("Daemon running interactively, exiting.")
-		os.Exit(0) // Consider removing this for more graceful shutdown in production daemons.
+	var missed []string
+	for _, r := range runners {
+		select {
+		case <-stopped[r]:
+		case <-ctx.Done():
+			// Once the deadline has passed, select may pick either ready case; check again.
+			select {
+			case <-stopped[r]:
+			default:
+				missed = append(missed, r.svc.Name())
+			}
+		}
 	}
 
-	return nil
+	mu.Lock()
+	defer mu.Unlock()
+	if pidFile != nil {
+		if err := pidFile.Release(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(missed) > 0 {
+		err := fmt.Errorf("services did not stop within %s: %s", d.stopTimeout, strings.Join(missed, ", "))
+		slog.Error("Daemon stop timed out", "error", err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
 type Controller struct {
 	service service.Service // service is the underlying service.Service instance.
+	daemon  *Daemon         // daemon is the daemon run by the service.
 }
 
 // NewController creates and returns a new Controller instance.
 // It sets up the daemon and registers it with the system's service manager.
 func NewController(conf *service.Config, services ...Service) (*Controller, error) {
-	d := NewDaemon(conf, services...)
+	return NewControllerWithOptions(conf, services)
+}
+
+// NewControllerWithOptions creates and returns a new Controller instance whose daemon
+// is configured by the optional Option functions.
+func NewControllerWithOptions(conf *service.Config, services []Service, opts ...Option) (*Controller, error) {
+	d, err := NewDaemonWithOptions(conf, services, opts...)
+	if err != nil {
+		return nil, err
+	}
 
-	// Apply platform-specific options.
-	// For systemd on Linux, increase the file descriptor limit.
+	// Apply platform-specific options, keeping those set by the caller.
 	if service.Platform() == "linux-systemd" {
-		d.config.Option = service.KeyValue{
-			"LimitNOFILE": 40960, // Set a higher limit for open file descriptors.
-		}
+		d.applySystemd()
 	}
 
 	// Create a new service instance with the daemon and its configuration.
@@ -117,9 +542,34 @@ func NewController(conf *service.Config, services ...Service) (*Controller, erro
 
 	return &Controller{
 		service: s,
+		daemon:  d,
 	}, nil
 }
 
+// Daemon returns the daemon run by the controller, e.g. to serve its status with Daemon.Handler.
+func (d *Controller) Daemon() *Daemon {
+	return d.daemon
+}
+
+// Status returns the status of the installed service as reported by the system's service manager.
+// The status of the individual services is only known to the running process, see Daemon.Status.
+func (d *Controller) Status() (service.Status, error) {
+	status, err := d.service.Status()
+	if err != nil {
+		return service.StatusUnknown, fmt.Errorf("failed to query service status: %w", err)
+	}
+	return status, nil
+}
+
+// PID reads the PID file set with WithPIDFile and reports whether the daemon process is alive,
+// whether it runs as a service or in the foreground. See QueryPIDFile.
+func (d *Controller) PID() (pid int, running bool, err error) {
+	if d.daemon.pidPath == "" {
+		return 0, false, errors.New("no PID file configured")
+	}
+	return QueryPIDFile(d.daemon.pidPath)
+}
+
 // Install installs the daemon as a system service.
 func (d *Controller) Install() error {
 	slog.Info(fmt.Sprintf("Installing service: %s", d.service.String()))
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// testService is a Service whose behaviour is controlled by the test.
+type testService struct {
+	name     string
+	run      func(ctx context.Context) error
+	shutdown func(ctx context.Context) error
+	errs     atomic.Int32 // errs counts the calls to HandleError.
+}
+
+func (s *testService) Name() string { return s.name }
+
+func (s *testService) Run(ctx context.Context) error { return s.run(ctx) }
+
+func (s *testService) HandleError(error) { s.errs.Add(1) }
+
+// shutdownService is a testService implementing Shutdowner.
+type shutdownService struct {
+	*testService
+}
+
+func (s shutdownService) Shutdown(ctx context.Context) error { return s.shutdown(ctx) }
+
+// blocking returns a Run function that blocks until ctx is cancelled.
+func blocking(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newTestDaemon(opts []Option, services ...Service) *Daemon {
+	d, err := NewDaemonWithOptions(&service.Config{Name: "test"}, services, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestDaemon_StartStop(t *testing.T) {
+	a := &testService{name: "a", run: blocking}
+	stopped := make(chan struct{}, 1)
+	b := shutdownService{&testService{name: "b"}}
+	b.run = func(ctx context.Context) error {
+		// b only returns once Shutdown has been called.
+		<-stopped
+		return nil
+	}
+	b.shutdown = func(ctx context.Context) error {
+		stopped <- struct{}{}
+		return nil
+	}
+
+	d := newTestDaemon(nil, a, b)
+	assert.NoError(t, d.Start(nil))
+	assert.Error(t, d.Start(nil))
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, int32(0), a.errs.Load())
+	assert.Equal(t, int32(0), b.errs.Load())
+
+	// Stopping a stopped daemon is a no-op and it can be started again.
+	assert.NoError(t, d.Stop(nil))
+	assert.NoError(t, d.Start(nil))
+	assert.NoError(t, d.Stop(nil))
+}
+
+func TestDaemon_HandleError(t *testing.T) {
+	failed := make(chan struct{})
+	s := &testService{name: "failing", run: func(ctx context.Context) error {
+		defer close(failed)
+		return errors.New("boom")
+	}}
+
+	d := newTestDaemon(nil, s)
+	assert.NoError(t, d.Start(nil))
+	<-failed
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, int32(1), s.errs.Load())
+}
+
+func TestDaemon_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stuck := &testService{name: "stuck", run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}}
+	fast := &testService{name: "fast", run: blocking}
+	failing := shutdownService{&testService{name: "failing", run: blocking}}
+	failing.shutdown = func(ctx context.Context) error {
+		return errors.New("shutdown failed")
+	}
+
+	d := newTestDaemon([]Option{WithStopTimeout(50 * time.Millisecond)}, stuck, fast, failing)
+	assert.NoError(t, d.Start(nil))
+	start := time.Now()
+	err := d.Stop(nil)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorContains(t, err, "did not stop within 50ms: stuck")
+	assert.NotContains(t, err.Error(), "fast")
+	assert.ErrorContains(t, err, "service failing failed to shut down: shutdown failed")
+}
+
+func TestDaemon_Panic(t *testing.T) {
+	var runs atomic.Int32
+	errs := make(chan error, 1)
+	buggy := &testService{name: "buggy", run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			var m map[string]int
+			m["boom"]++
+		}
+		return blocking(ctx)
+	}}
+	server := &testService{name: "server", run: blocking}
+
+	d := newTestDaemon([]Option{WithRestart("buggy", RestartConfig{Policy: RestartOnFailure, InitialBackoff: time.Millisecond})},
+		panicHandler{buggy, errs}, server)
+	assert.NoError(t, d.Start(nil))
+
+	// The panic is reported as an error with a stack trace and the service is restarted.
+	err := <-errs
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "buggy", panicErr.Service)
+	assert.ErrorContains(t, err, "assignment to entry in nil map")
+	assert.Contains(t, string(panicErr.Stack), "TestDaemon_Panic")
+	var runtimeErr runtime.Error
+	assert.True(t, errors.As(err, &runtimeErr))
+	assert.Eventually(t, func() bool { return runs.Load() == 2 }, time.Second, time.Millisecond)
+
+	assert.NoError(t, d.Stop(nil))
+}
+
+// panicHandler is a testService that sends the errors passed to HandleError.
+type panicHandler struct {
+	*testService
+	errs chan error
+}
+
+func (s panicHandler) HandleError(err error) { s.errs <- err }
@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDependencyFailed is reported for a service that wasn't started because one of its dependencies
+// was given up on before it was ready. The error names the dependency and wraps its error.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// Dependent is implemented by services that must start after other services, such as a message consumer
+// that needs the database migrations to be applied. The daemon runs a service only once all its dependencies
+// are ready, and stops it before them.
+type Dependent interface {
+	// Dependencies returns the names of the services this service depends on.
+	Dependencies() []string
+}
+
+// ReadySignaler is implemented by services that take a while to become ready, such as a migration or a server
+// that must bind its port. Their dependents only start once Run calls Ready with its context, or Run returns nil.
+// Services not implementing ReadySignaler, or whose SignalsReady method returns false, are ready as soon as Run is called.
+type ReadySignaler interface {
+	SignalsReady() bool
+}
+
+// readyKey is the context key of the function marking a service as ready.
+type readyKey struct{}
+
+// Ready marks the service whose Run method received ctx as ready, so its dependents are started.
+// It can be called more than once and does nothing if ctx wasn't passed to Run by a Daemon.
+func Ready(ctx context.Context) {
+	if ready, ok := ctx.Value(readyKey{}).(func()); ok {
+		ready()
+	}
+}
+
+// signalsReady reports whether s signals its readiness with Ready.
+func signalsReady(s Service) bool {
+	signaler, ok := s.(ReadySignaler)
+	return ok && signaler.SignalsReady()
+}
+
+// dependencies returns the names of the services s depends on.
+func dependencies(s Service) []string {
+	if dependent, ok := s.(Dependent); ok {
+		return dependent.Dependencies()
+	}
+	return nil
+}
+
+// sortServices returns the services in an order in which every service comes after its dependencies.
+// Services keep their given order, except that dependencies are moved before the first service needing them.
+// It returns an error if service names are not unique, a dependency doesn't exist or the dependencies form a cycle.
+func sortServices(services []Service) ([]Service, error) {
+	index := make(map[string]int, len(services))
+	for i, s := range services {
+		if _, ok := index[s.Name()]; ok {
+			return nil, fmt.Errorf("duplicate service name %q", s.Name())
+		}
+		index[s.Name()] = i
+	}
+	for _, s := range services {
+		for _, dep := range dependencies(s) {
+			if _, ok := index[dep]; !ok {
+				return nil, fmt.Errorf("service %s depends on unknown service %q", s.Name(), dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make([]int, len(services))
+	sorted := make([]Service, 0, len(services))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch marks[i] {
+		case visited:
+			return nil
+		case visiting:
+			// Report the cycle starting at the service visited again.
+			start := 0
+			for path[start] != services[i].Name() {
+				start++
+			}
+			cycle := append(path[start:len(path):len(path)], services[i].Name())
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+		marks[i] = visiting
+		path = append(path, services[i].Name())
+		for _, dep := range dependencies(services[i]) {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[i] = visited
+		sorted = append(sorted, services[i])
+		return nil
+	}
+	for i := range services {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// depService is a testService declaring dependencies and, optionally, signalling readiness.
+type depService struct {
+	*testService
+	deps    []string
+	signals bool
+}
+
+func (s depService) Dependencies() []string { return s.deps }
+
+func (s depService) SignalsReady() bool { return s.signals }
+
+// eventLog records the order of events across services.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func TestSortServices(t *testing.T) {
+	named := func(name string, deps ...string) Service {
+		return depService{testService: &testService{name: name}, deps: deps}
+	}
+	names := func(services []Service) []string {
+		var names []string
+		for _, s := range services {
+			names = append(names, s.Name())
+		}
+		return names
+	}
+
+	sorted, err := sortServices([]Service{named("consumer", "migration", "cache"), named("cache"), named("migration")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migration", "cache", "consumer"}, names(sorted))
+
+	_, err = sortServices([]Service{named("a", "b"), named("b", "c"), named("c", "a")})
+	assert.EqualError(t, err, "dependency cycle: a -> b -> c -> a")
+	_, err = sortServices([]Service{named("a", "a")})
+	assert.EqualError(t, err, "dependency cycle: a -> a")
+	_, err = sortServices([]Service{named("a", "missing")})
+	assert.ErrorContains(t, err, "unknown service")
+	_, err = sortServices([]Service{named("a"), named("a")})
+	assert.ErrorContains(t, err, "duplicate service name")
+
+	_, err = NewDaemonWithOptions(&service.Config{Name: "test"}, []Service{named("a", "b"), named("b", "a")})
+	assert.ErrorContains(t, err, "dependency cycle")
+	d := NewDaemon(&service.Config{Name: "test"}, named("a", "b"), named("b", "a"))
+	assert.ErrorContains(t, d.Start(nil), "dependency cycle")
+}
+
+func TestDaemon_Dependencies(t *testing.T) {
+	log := &eventLog{}
+	release := make(chan struct{})
+	migration := depService{testService: &testService{name: "migration", run: func(ctx context.Context) error {
+		log.add("migration started")
+		<-release
+		log.add("migration done")
+		return nil
+	}}, signals: true}
+	server := depService{testService: &testService{name: "server"}, signals: true}
+	server.run = func(ctx context.Context) error {
+		log.add("server started")
+		Ready(ctx)
+		<-ctx.Done()
+		log.add("server stopped")
+		return nil
+	}
+	consumer := depService{testService: &testService{name: "consumer"}, deps: []string{"migration", "server"}}
+	consumer.run = func(ctx context.Context) error {
+		log.add("consumer started")
+		<-ctx.Done()
+		log.add("consumer stopped")
+		return nil
+	}
+
+	d := newTestDaemon(nil, consumer, migration, server)
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool { return len(log.get()) == 2 }, time.Second, time.Millisecond)
+	// The consumer waits for the migration to complete.
+	time.Sleep(20 * time.Millisecond)
+	assert.NotContains(t, log.get(), "consumer started")
+	assert.Equal(t, StateStarting, stateOf(d, "consumer").State)
+	assert.False(t, d.Status().Ready())
+
+	close(release)
+	assert.Eventually(t, func() bool { return stateOf(d, "consumer").State == StateRunning }, time.Second, time.Millisecond)
+	assert.NoError(t, d.Stop(nil))
+
+	// The consumer stops before the server it depends on.
+	events := log.get()
+	assert.Equal(t, []string{"migration done", "consumer started", "consumer stopped", "server stopped"}, events[2:])
+}
+
+func TestDaemon_DependencyFailed(t *testing.T) {
+	migration := depService{testService: &testService{name: "migration"}, signals: true}
+	migration.run = func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}
+	started := make(chan struct{}, 2)
+	consumer := depService{testService: &testService{name: "consumer"}, deps: []string{"migration"}}
+	consumer.run = func(ctx context.Context) error {
+		started <- struct{}{}
+		return blocking(ctx)
+	}
+	api := depService{testService: &testService{name: "api"}, deps: []string{"consumer"}}
+	api.run = consumer.run
+
+	d := newTestDaemon(nil, migration, consumer, api)
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+
+	// A dependency that fails before it is ready fails its dependents, transitively.
+	assert.Eventually(t, func() bool { return stateOf(d, "api").State == StateFailed }, time.Second, time.Millisecond)
+	consumerStatus := stateOf(d, "consumer")
+	assert.Equal(t, StateFailed, consumerStatus.State)
+	assert.Equal(t, "dependency failed: migration: connection refused", consumerStatus.LastError)
+	assert.Equal(t, "dependency failed: consumer: dependency failed: migration: connection refused", stateOf(d, "api").LastError)
+	assert.Len(t, started, 0)
+	assert.False(t, d.Status().Ready())
+}
+
+func TestDaemon_ReadySignal(t *testing.T) {
+	started := make(chan struct{})
+	slow := depService{testService: &testService{name: "slow"}, signals: true}
+	slow.run = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	dependent := depService{testService: &testService{name: "dependent"}, deps: []string{"slow"}}
+	dependent.run = func(ctx context.Context) error {
+		close(started)
+		return blocking(ctx)
+	}
+
+	d := newTestDaemon([]Option{WithStopTimeout(time.Second)}, slow, dependent)
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool { return stateOf(d, "slow").State == StateStarting }, time.Second, time.Millisecond)
+
+	// A service that never signals readiness holds back its dependents, which are stopped cleanly.
+	select {
+	case <-started:
+		t.Fatal("dependent started before its dependency was ready")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, StateStopped, stateOf(d, "dependent").State)
+	assert.NoError(t, d.Stop(nil))
+
+	// Ready does nothing outside a daemon.
+	Ready(context.Background())
+}
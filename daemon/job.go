@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultJobHistory is the number of runs kept in the history of a job if Job.History is not set.
+var DefaultJobHistory = 10
+
+// OverlapPolicy determines what happens when a job is due while its previous run hasn't finished.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run. It is the default.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs the job again as soon as the previous run finishes. Runs due while one is queued are skipped.
+	OverlapQueue
+	// OverlapAllow runs the job concurrently with the previous run.
+	OverlapAllow
+)
+
+// String returns the name of the policy.
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	case OverlapAllow:
+		return "allow"
+	default:
+		return "unknown"
+	}
+}
+
+// Job is a task run by a Scheduler, such as a cleanup, a report or a token refresh.
+type Job struct {
+	Name     string                          // 任务名称, 在调度器内唯一
+	Run      func(ctx context.Context) error // 任务逻辑, ctx 在超时或守护进程停止时取消
+	Schedule Schedule                        // 运行计划, 见 Every 和 ParseCron; 为 nil 时只在启动时运行一次
+	// RunOnStart runs the job once when the scheduler starts, in addition to its schedule.
+	RunOnStart bool
+	Jitter     time.Duration // 每次运行前增加的随机延迟, 取值范围 [0, Jitter)
+	Timeout    time.Duration // 单次运行的超时时间, 为 0 时不限制
+	Overlap    OverlapPolicy // 上次运行未结束时的处理策略
+	History    int           // 保留的运行记录数, 为 0 时使用 DefaultJobHistory
+}
+
+// JobRun is the record of a run of a job.
+type JobRun struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// JobStatus is the status of a job, reported in the Details of the scheduler's ServiceStatus.
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule,omitempty"`
+	Overlap  string    `json:"overlap"`
+	Next     time.Time `json:"next,omitzero"` // Next is when the job is due next.
+	Running  int       `json:"running"`       // Running is the number of runs in progress.
+	Queued   bool      `json:"queued"`        // Queued reports whether a run waits for the current one to finish.
+	Runs     int       `json:"runs"`          // Runs counts the finished runs.
+	Failures int       `json:"failures"`      // Failures counts the runs that returned an error.
+	Skipped  int       `json:"skipped"`       // Skipped counts the runs skipped because of the overlap policy.
+	History  []JobRun  `json:"history"`       // History holds the most recent runs, latest first.
+}
+
+// Scheduler is a Service running jobs on their schedules. Add it to a Daemon like any other service;
+// its status Details hold the JobStatus of every job. Errors returned by jobs are logged and recorded
+// in their history, but don't stop the scheduler.
+type Scheduler struct {
+	name string
+	jobs []*jobState
+	wg   sync.WaitGroup // 正在运行的任务
+}
+
+// jobState tracks the runs of a job.
+type jobState struct {
+	job Job
+
+	mu       sync.Mutex
+	next     time.Time
+	running  int
+	queued   bool
+	runs     int
+	failures int
+	skipped  int
+	history  []JobRun // 最近的运行记录, 最新的在前
+}
+
+// NewScheduler creates a Scheduler service with the given name running jobs.
+// It returns an error if a job has no name or Run function, or job names are not unique.
+func NewScheduler(name string, jobs ...Job) (*Scheduler, error) {
+	s := &Scheduler{name: name}
+	names := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		if job.Name == "" || job.Run == nil {
+			return nil, errors.New("job must have a name and a Run function")
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		names[job.Name] = true
+		if job.History <= 0 {
+			job.History = DefaultJobHistory
+		}
+		s.jobs = append(s.jobs, &jobState{job: job})
+	}
+	return s, nil
+}
+
+// Name returns the name of the scheduler service.
+func (s *Scheduler) Name() string {
+	return s.name
+}
+
+// Run runs the jobs on their schedules until ctx is cancelled, then waits for the runs in progress,
+// whose contexts are cancelled as well.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var loops sync.WaitGroup
+	for _, j := range s.jobs {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	loops.Wait()
+	s.wg.Wait()
+	return nil
+}
+
+// HandleError logs errors of the scheduler.
+func (s *Scheduler) HandleError(err error) {
+	slog.Error("Scheduler error", "scheduler", s.name, "error", err)
+}
+
+// StatusDetails returns the status of the jobs.
+func (s *Scheduler) StatusDetails() any {
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status())
+	}
+	return statuses
+}
+
+// loop triggers the runs of a job until ctx is cancelled or the schedule has no more activations.
+func (s *Scheduler) loop(ctx context.Context, j *jobState) {
+	if j.job.RunOnStart || j.job.Schedule == nil {
+		s.trigger(ctx, j)
+	}
+	if j.job.Schedule == nil {
+		return
+	}
+
+	due := time.Now()
+	for {
+		now := time.Now()
+		due = j.job.Schedule.Next(due)
+		if !due.IsZero() && !due.After(now) {
+			// The previous run was late, e.g. the machine was suspended: continue from now.
+			due = j.job.Schedule.Next(now)
+		}
+		if due.IsZero() {
+			j.setNext(time.Time{})
+			return
+		}
+		at := due
+		if j.job.Jitter > 0 {
+			at = at.Add(rand.N(j.job.Jitter))
+		}
+		j.setNext(at)
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+			s.trigger(ctx, j)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// trigger starts a run of the job according to its overlap policy.
+func (s *Scheduler) trigger(ctx context.Context, j *jobState) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running > 0 && j.job.Overlap != OverlapAllow {
+		if j.job.Overlap == OverlapQueue && !j.queued {
+			j.queued = true
+			return
+		}
+		j.skipped++
+		slog.Warn(fmt.Sprintf("Job %s is still running, skipping run.", j.job.Name), "scheduler", s.name)
+		return
+	}
+	j.running++
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(ctx, j)
+	}()
+}
+
+// execute runs the job, followed by the queued run if there is one.
+func (s *Scheduler) execute(ctx context.Context, j *jobState) {
+	for {
+		start := time.Now()
+		err := s.runJob(ctx, j)
+		j.record(JobRun{Start: start, Duration: time.Since(start)}, err)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Job %s failed.", j.job.Name), "scheduler", s.name, "error", err)
+		}
+
+		j.mu.Lock()
+		if j.queued && ctx.Err() == nil {
+			j.queued = false
+			j.mu.Unlock()
+			continue
+		}
+		j.queued = false
+		j.running--
+		j.mu.Unlock()
+		return
+	}
+}
+
+// runJob runs the job once with its timeout, converting a panic into an error.
+func (s *Scheduler) runJob(ctx context.Context, j *jobState) (err error) {
+	if j.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.job.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("job %s panicked: %v\n%s", j.job.Name, v, debug.Stack())
+		}
+	}()
+	return j.job.Run(ctx)
+}
+
+// setNext records when the job is due next.
+func (j *jobState) setNext(next time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.next = next
+}
+
+// record adds a finished run to the history.
+func (j *jobState) record(run JobRun, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.runs++
+	if err != nil {
+		j.failures++
+		run.Error = err.Error()
+	}
+	j.history = append([]JobRun{run}, j.history[:min(len(j.history), j.job.History-1)]...)
+}
+
+// status returns a snapshot of the status of the job.
+func (j *jobState) status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := JobStatus{
+		Name:     j.job.Name,
+		Overlap:  j.job.Overlap.String(),
+		Next:     j.next,
+		Running:  j.running,
+		Queued:   j.queued,
+		Runs:     j.runs,
+		Failures: j.failures,
+		Skipped:  j.skipped,
+		History:  append([]JobRun(nil), j.history...),
+	}
+	if stringer, ok := j.job.Schedule.(fmt.Stringer); ok {
+		status.Schedule = stringer.String()
+	}
+	return status
+}
@@ -0,0 +1,163 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jobStatus returns the status of the job with the given name.
+func jobStatus(s *Scheduler, name string) JobStatus {
+	for _, status := range s.StatusDetails().([]JobStatus) {
+		if status.Name == name {
+			return status
+		}
+	}
+	return JobStatus{}
+}
+
+func TestNewScheduler_Invalid(t *testing.T) {
+	run := func(ctx context.Context) error { return nil }
+	_, err := NewScheduler("jobs", Job{Name: "", Run: run})
+	assert.Error(t, err)
+	_, err = NewScheduler("jobs", Job{Name: "cleanup"})
+	assert.Error(t, err)
+	_, err = NewScheduler("jobs", Job{Name: "cleanup", Run: run}, Job{Name: "cleanup", Run: run})
+	assert.Error(t, err)
+}
+
+func TestScheduler_Interval(t *testing.T) {
+	var runs atomic.Int32
+	s, err := NewScheduler("jobs",
+		Job{Name: "refresh", Schedule: Every(5 * time.Millisecond), Jitter: time.Millisecond, History: 3,
+			Run: func(ctx context.Context) error {
+				if runs.Add(1)%2 == 0 {
+					return errors.New("token endpoint unavailable")
+				}
+				return nil
+			}},
+		Job{Name: "warmup", Run: func(ctx context.Context) error { return nil }},
+	)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	assert.Eventually(t, func() bool { return runs.Load() >= 5 }, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	status := jobStatus(s, "refresh")
+	assert.Equal(t, "@every 5ms", status.Schedule)
+	assert.GreaterOrEqual(t, status.Runs, 5)
+	assert.Equal(t, status.Runs/2, status.Failures)
+	assert.Len(t, status.History, 3)
+	assert.True(t, status.History[0].Start.After(status.History[1].Start))
+
+	// A job without a schedule runs once.
+	warmup := jobStatus(s, "warmup")
+	assert.Equal(t, 1, warmup.Runs)
+	assert.True(t, warmup.Next.IsZero())
+}
+
+func TestScheduler_Overlap(t *testing.T) {
+	for _, tt := range []struct {
+		policy  OverlapPolicy
+		maxRuns int32 // 同时运行的次数: skip 和 queue 最多 1 次, allow 至少 2 次
+	}{
+		{OverlapSkip, 1},
+		{OverlapQueue, 1},
+		{OverlapAllow, 2},
+	} {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			var running, maxRunning atomic.Int32
+			release := make(chan struct{})
+			s, err := NewScheduler("jobs", Job{Name: "report", Schedule: Every(2 * time.Millisecond), Overlap: tt.policy,
+				Run: func(ctx context.Context) error {
+					n := running.Add(1)
+					defer running.Add(-1)
+					for {
+						m := maxRunning.Load()
+						if n <= m || maxRunning.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					select {
+					case <-release:
+					case <-ctx.Done():
+					}
+					return nil
+				}})
+			assert.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- s.Run(ctx) }()
+			assert.Eventually(t, func() bool {
+				status := jobStatus(s, "report")
+				switch tt.policy {
+				case OverlapSkip:
+					return status.Skipped > 0
+				case OverlapQueue:
+					return status.Queued && status.Skipped > 0
+				default:
+					// The scheduler counts a run before it enters Run, so wait for the job's own counter.
+					return running.Load() >= 2
+				}
+			}, time.Second, time.Millisecond)
+			close(release)
+			if tt.policy == OverlapQueue {
+				// The queued run follows the finished one.
+				assert.Eventually(t, func() bool { return jobStatus(s, "report").Runs >= 2 }, time.Second, time.Millisecond)
+			}
+			cancel()
+			assert.NoError(t, <-done)
+			if tt.policy == OverlapAllow {
+				assert.GreaterOrEqual(t, maxRunning.Load(), tt.maxRuns)
+			} else {
+				assert.Equal(t, tt.maxRuns, maxRunning.Load())
+			}
+		})
+	}
+}
+
+func TestScheduler_TimeoutAndPanic(t *testing.T) {
+	s, err := NewScheduler("jobs",
+		Job{Name: "slow", Timeout: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		Job{Name: "buggy", Run: func(ctx context.Context) error {
+			panic("bug")
+		}},
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Run(context.Background()))
+
+	slow := jobStatus(s, "slow")
+	assert.Equal(t, 1, slow.Failures)
+	assert.Equal(t, context.DeadlineExceeded.Error(), slow.History[0].Error)
+	assert.GreaterOrEqual(t, slow.History[0].Duration, 5*time.Millisecond)
+	assert.Contains(t, jobStatus(s, "buggy").History[0].Error, "job buggy panicked: bug")
+}
+
+func TestDaemon_SchedulerStatus(t *testing.T) {
+	s, err := NewScheduler("jobs", Job{Name: "cleanup", Schedule: MustParseCron("@hourly"),
+		Run: func(ctx context.Context) error { return nil }})
+	assert.NoError(t, err)
+	d := newTestDaemon(nil, s)
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+
+	assert.Eventually(t, func() bool {
+		jobs, ok := stateOf(d, "jobs").Details.([]JobStatus)
+		return ok && len(jobs) == 1 && !jobs[0].Next.IsZero()
+	}, time.Second, time.Millisecond)
+	jobs := stateOf(d, "jobs").Details.([]JobStatus)
+	assert.Equal(t, "@hourly", jobs[0].Schedule)
+	assert.Equal(t, 0, jobs[0].Next.Minute())
+}
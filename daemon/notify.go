@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Messages of the systemd notification protocol, see sd_notify(3).
+const (
+	NotifyReady     = "READY=1"     // NotifyReady tells systemd that the service finished starting up.
+	NotifyReloading = "RELOADING=1" // NotifyReloading tells systemd that the service is reloading its configuration.
+	NotifyStopping  = "STOPPING=1"  // NotifyStopping tells systemd that the service is shutting down.
+	NotifyWatchdog  = "WATCHDOG=1"  // NotifyWatchdog resets the systemd watchdog timer.
+)
+
+// Notifier sends notifications to systemd over the datagram socket given by the NOTIFY_SOCKET
+// environment variable, which systemd sets for services with Type=notify or WatchdogSec.
+// A nil Notifier discards the notifications, so it can be used when not running under systemd.
+type Notifier struct {
+	addr *net.UnixAddr // 通知套接字的地址
+}
+
+// NewNotifier returns a Notifier sending to the unixgram socket at path. A path starting with '@'
+// refers to an abstract socket. It returns nil if path is empty.
+func NewNotifier(path string) *Notifier {
+	if path == "" {
+		return nil
+	}
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	return &Notifier{addr: &net.UnixAddr{Name: path, Net: "unixgram"}}
+}
+
+// NotifierFromEnv returns a Notifier sending to the socket given by the NOTIFY_SOCKET environment variable,
+// or nil if it is not set.
+func NotifierFromEnv() *Notifier {
+	return NewNotifier(os.Getenv("NOTIFY_SOCKET"))
+}
+
+// Notify sends the newline-separated variable assignments in state, e.g. NotifyReady or "STATUS=Migrating".
+// It does nothing if n is nil.
+func (n *Notifier) Notify(state string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// WatchdogInterval returns the interval at which the watchdog should be notified: half the timeout
+// set by systemd in the WATCHDOG_USEC environment variable. It returns 0 if the watchdog is not enabled
+// for this process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// notify sends state with the daemon's notifier, logging failures.
+func (d *Daemon) notify(state string) {
+	if err := d.notifier.Notify(state); err != nil {
+		slog.Warn("Failed to notify systemd", "state", state, "error", err)
+	}
+}
+
+// supervise notifies systemd once all services that weren't given up on are ready, then notifies the watchdog
+// at every watchdog interval while the daemon is healthy, until ctx is cancelled. Services that failed before
+// they were ready are listed in the STATUS sent with READY=1.
+func (d *Daemon) supervise(ctx context.Context, runners []*runner) {
+	var failed []string
+	for _, r := range runners {
+		select {
+		case <-r.ready:
+		case <-r.failed:
+			failed = append(failed, r.svc.Name())
+		case <-ctx.Done():
+			return
+		}
+	}
+	if len(failed) == 0 {
+		slog.Info("All services are ready.")
+		d.notify(NotifyReady)
+	} else {
+		slog.Warn(fmt.Sprintf("Services are ready except the failed ones: %s.", strings.Join(failed, ", ")))
+		d.notify(NotifyReady + "\nSTATUS=Failed services: " + strings.Join(failed, ", "))
+	}
+	d.ready.Store(true)
+
+	if d.notifier == nil || d.watchdog <= 0 {
+		return
+	}
+	ticker := time.NewTicker(d.watchdog)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		// A check may take no longer than the watchdog interval, so a slow check can't make systemd
+		// kill a healthy daemon. Skipping the notification lets systemd restart an unhealthy one.
+		checkCtx, cancel := context.WithTimeout(ctx, min(d.healthTimeout, d.watchdog))
+		status := d.CheckHealth(checkCtx)
+		cancel()
+		if status.Healthy() {
+			d.notify(NotifyWatchdog)
+		} else if ctx.Err() == nil {
+			slog.Warn("Daemon is unhealthy, not notifying the watchdog.")
+		}
+	}
+}
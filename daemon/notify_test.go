@@ -0,0 +1,172 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listenNotify creates a unixgram socket standing in for systemd and returns a channel
+// receiving the notifications sent to it.
+func listenNotify(t *testing.T) (string, <-chan string) {
+	dir, err := os.MkdirTemp("", "notify")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	messages := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return path, messages
+}
+
+// receive returns the next notification, or fails the test after a timeout.
+func receive(t *testing.T, messages <-chan string) string {
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification received")
+		return ""
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	path, messages := listenNotify(t)
+	n := NewNotifier(path)
+	assert.NoError(t, n.Notify("STATUS=Migrating\nMAINPID=1"))
+	assert.Equal(t, "STATUS=Migrating\nMAINPID=1", receive(t, messages))
+
+	// A nil notifier discards notifications.
+	assert.Nil(t, NewNotifier(""))
+	var none *Notifier
+	assert.NoError(t, none.Notify(NotifyReady))
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	assert.NoError(t, NotifierFromEnv().Notify(NotifyReady))
+	assert.Equal(t, NotifyReady, receive(t, messages))
+
+	assert.Error(t, NewNotifier(filepath.Join(t.TempDir(), "missing.sock")).Notify(NotifyReady))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+	t.Setenv("WATCHDOG_USEC", "10000000")
+	assert.Equal(t, 5*time.Second, WatchdogInterval())
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 5*time.Second, WatchdogInterval())
+	t.Setenv("WATCHDOG_PID", "1")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+}
+
+func TestDaemon_Notify(t *testing.T) {
+	path, messages := listenNotify(t)
+	release := make(chan struct{})
+	slow := depService{testService: &testService{name: "slow"}, signals: true}
+	slow.run = func(ctx context.Context) error {
+		<-release
+		Ready(ctx)
+		return blocking(ctx)
+	}
+	db := &healthService{testService: &testService{name: "db", run: blocking}}
+
+	d := newTestDaemon([]Option{WithNotifier(NewNotifier(path)), WithWatchdog(10 * time.Millisecond)}, slow, db)
+	assert.NoError(t, d.Start(nil))
+
+	// READY=1 is only sent once all services are ready.
+	select {
+	case m := <-messages:
+		t.Fatalf("unexpected notification %q", m)
+	case <-time.After(30 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, NotifyReady, receive(t, messages))
+	assert.Equal(t, NotifyWatchdog, receive(t, messages))
+
+	// The watchdog isn't notified while a health check fails.
+	db.unhealthy.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	for len(messages) > 0 {
+		<-messages
+	}
+	select {
+	case m := <-messages:
+		t.Fatalf("unexpected notification %q", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+	db.unhealthy.Store(false)
+	assert.Equal(t, NotifyWatchdog, receive(t, messages))
+
+	assert.NoError(t, d.Stop(nil))
+	for m := receive(t, messages); m != NotifyStopping; m = receive(t, messages) {
+		assert.Equal(t, NotifyWatchdog, m)
+	}
+}
+
+func TestDaemon_NotifyFailedBeforeReady(t *testing.T) {
+	path, messages := listenNotify(t)
+	broken := depService{testService: &testService{name: "broken"}, signals: true}
+	broken.run = func(ctx context.Context) error {
+		return errors.New("bind: address already in use")
+	}
+	db := &testService{name: "db", run: blocking}
+
+	// A non-critical service failing before it is ready doesn't hold back READY=1, which reports it.
+	d := newTestDaemon([]Option{WithNotifier(NewNotifier(path))}, broken, db)
+	assert.NoError(t, d.Start(nil))
+	assert.Equal(t, NotifyReady+"\nSTATUS=Failed services: broken", receive(t, messages))
+	assert.Equal(t, StateFailed, stateOf(d, "broken").State)
+	assert.Eventually(t, func() bool { return stateOf(d, "db").State == StateRunning }, time.Second, time.Millisecond)
+
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, NotifyStopping, receive(t, messages))
+}
+
+// slowHealthService is a testService whose health check blocks until its context is done.
+type slowHealthService struct {
+	*testService
+	checks atomic.Int32
+}
+
+func (s *slowHealthService) CheckHealth(ctx context.Context) error {
+	s.checks.Add(1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDaemon_WatchdogHealthTimeout(t *testing.T) {
+	path, messages := listenNotify(t)
+	slow := &slowHealthService{testService: &testService{name: "slow", run: blocking}}
+
+	// Each check is bounded by the watchdog interval rather than the much longer health timeout.
+	d := newTestDaemon([]Option{WithNotifier(NewNotifier(path)), WithWatchdog(10 * time.Millisecond), WithHealthTimeout(time.Minute)}, slow)
+	assert.NoError(t, d.Start(nil))
+	assert.Equal(t, NotifyReady, receive(t, messages))
+	assert.Eventually(t, func() bool {
+		return slow.checks.Load() >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, NotifyStopping, receive(t, messages))
+}
@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyRunning is returned when the PID file of the daemon is locked by another running instance.
+var ErrAlreadyRunning = errors.New("daemon is already running")
+
+// lockRetryTimeout is how long AcquirePIDFile retries a locked file, since QueryPIDFile holds a shared lock
+// on it for a moment. lockRetryInterval is the delay between attempts.
+const (
+	lockRetryTimeout  = 100 * time.Millisecond
+	lockRetryInterval = 10 * time.Millisecond
+)
+
+// PIDFile is a file holding the PID of the running daemon. The file is locked for as long as the daemon runs,
+// so a second instance using the same file fails to start, and a file left behind by a crashed instance
+// is recognized as stale because nobody holds its lock.
+type PIDFile struct {
+	path string
+	file *os.File
+}
+
+// AcquirePIDFile locks the PID file at path, creating it if needed, and writes the PID of the current process
+// to it. It returns an error wrapping ErrAlreadyRunning if another process holds the lock.
+// A stale PID left by a process that exited without releasing the file is replaced.
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	deadline := time.Now().Add(lockRetryTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PID file: %w", err)
+		}
+		locked, err := tryLock(f, true)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock PID file %s: %w", path, err)
+		}
+		if !locked && time.Now().Before(deadline) {
+			// The lock may be held by a query rather than a running instance: try again shortly.
+			_ = f.Close()
+			time.Sleep(lockRetryInterval)
+			continue
+		}
+		if !locked {
+			pid, _ := readPID(f)
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: PID file %s is locked by process %d", ErrAlreadyRunning, path, pid)
+		}
+
+		// The previous owner may have removed the file between our open and lock: start over with the new file.
+		opened, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to stat PID file: %w", err)
+		}
+		if current, err := os.Stat(path); err != nil || !os.SameFile(opened, current) {
+			_ = f.Close()
+			continue
+		}
+
+		if pid, _ := readPID(f); pid != 0 {
+			slog.Warn(fmt.Sprintf("Replacing stale PID %d in %s.", pid, path))
+		}
+		p := &PIDFile{path: path, file: f}
+		if err := p.write(os.Getpid()); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return p, nil
+	}
+}
+
+// Path returns the path of the PID file.
+func (p *PIDFile) Path() string {
+	return p.path
+}
+
+// Release removes the PID file and releases its lock.
+func (p *PIDFile) Release() error {
+	if err := closeAndRemove(p.file, p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove PID file: %w", err)
+	}
+	return nil
+}
+
+// write replaces the content of the file with pid.
+func (p *PIDFile) write(pid int) error {
+	if err := p.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate PID file: %w", err)
+	}
+	if _, err := p.file.WriteAt([]byte(strconv.Itoa(pid)+"\n"), 0); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync PID file: %w", err)
+	}
+	return nil
+}
+
+// QueryPIDFile reads the PID file at path and reports whether the process it names is alive, that is,
+// still holds the lock on the file. It returns 0 and false if the file doesn't exist,
+// and the stale PID and false if the process exited without removing it.
+func QueryPIDFile(path string) (pid int, running bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to open PID file: %w", err)
+	}
+	defer f.Close()
+
+	if pid, err = readPID(f); err != nil {
+		return 0, false, err
+	}
+	// A shared lock is enough to probe the owner's exclusive lock, and doesn't conflict with other queries.
+	locked, err := tryLock(f, false)
+	if err != nil {
+		return pid, false, fmt.Errorf("failed to lock PID file %s: %w", path, err)
+	}
+	// The lock is released when f is closed.
+	return pid, !locked && pid != 0, nil
+}
+
+// readPID reads the PID from the beginning of f. It returns 0 if f is empty.
+func readPID(f *os.File) (int, error) {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 64))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PID file: %w", err)
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(s)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID file content %q", s)
+	}
+	return pid, nil
+}
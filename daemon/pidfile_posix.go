@@ -0,0 +1,30 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive or shared flock on f without blocking. It returns false if another process
+// holds a conflicting lock.
+func tryLock(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// closeAndRemove removes the locked file f at path and closes it, releasing the lock.
+// The file is removed first, so no other process can lock it in between and lose its PID file.
+func closeAndRemove(f *os.File, path string) error {
+	err := os.Remove(path)
+	return errors.Join(err, f.Close())
+}
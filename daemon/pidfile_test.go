@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	pid, running, err := QueryPIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pid)
+	assert.False(t, running)
+
+	p, err := AcquirePIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, path, p.Path())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+
+	pid, running, err = QueryPIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.True(t, running)
+
+	// A second instance can't acquire the file.
+	_, err = AcquirePIDFile(path)
+	assert.True(t, errors.Is(err, ErrAlreadyRunning))
+	assert.ErrorContains(t, err, "locked by process "+strconv.Itoa(os.Getpid()))
+
+	assert.NoError(t, p.Release())
+	_, err = os.Stat(path)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestPIDFile_Stale(t *testing.T) {
+	// A PID file left behind by a crashed process isn't locked.
+	path := filepath.Join(t.TempDir(), "app.pid")
+	assert.NoError(t, os.WriteFile(path, []byte("999999\n"), 0o644))
+	pid, running, err := QueryPIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 999999, pid)
+	assert.False(t, running)
+
+	p, err := AcquirePIDFile(path)
+	assert.NoError(t, err)
+	defer p.Release()
+	pid, running, err = QueryPIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.True(t, running)
+
+	bad := filepath.Join(t.TempDir(), "bad.pid")
+	assert.NoError(t, os.WriteFile(bad, []byte("abc"), 0o644))
+	_, _, err = QueryPIDFile(bad)
+	assert.Error(t, err)
+}
+
+func TestPIDFile_QueryDoesNotBlockOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	assert.NoError(t, os.WriteFile(path, []byte("999999\n"), 0o644))
+
+	// A query probes with a shared lock, which an owner starting at the same moment waits out.
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	locked, err := tryLock(f, false)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	pid, running, err := QueryPIDFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 999999, pid)
+	assert.False(t, running)
+	time.AfterFunc(20*time.Millisecond, func() { _ = f.Close() })
+
+	p, err := AcquirePIDFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, p.Release())
+}
+
+func TestDaemon_PIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	first := newTestDaemon([]Option{WithPIDFile(path)}, &testService{name: "a", run: blocking})
+	second := newTestDaemon([]Option{WithPIDFile(path)}, &testService{name: "a", run: blocking})
+
+	assert.NoError(t, first.Start(nil))
+	assert.True(t, errors.Is(second.Start(nil), ErrAlreadyRunning))
+	assert.False(t, second.Status().Running)
+
+	c := &Controller{daemon: second}
+	pid, running, err := c.PID()
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.True(t, running)
+
+	// The PID file is removed when the daemon stops, and the next instance can start.
+	assert.NoError(t, first.Stop(nil))
+	_, running, err = c.PID()
+	assert.NoError(t, err)
+	assert.False(t, running)
+	assert.NoError(t, second.Start(nil))
+	assert.NoError(t, second.Stop(nil))
+
+	_, _, err = (&Controller{daemon: newTestDaemon(nil)}).PID()
+	assert.Error(t, err)
+}
+
+func TestController_ReloadPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	d := newTestDaemon([]Option{WithPIDFile(path)})
+	d.config = &service.Config{Name: "app"}
+	c := &Controller{daemon: d}
+	assert.ErrorContains(t, c.reload("linux-systemd"), "not running")
+
+	// With a PID file, the reload signal is sent to the process it names.
+	logger := &reloadService{testService: &testService{name: "logger", run: blocking}}
+	running := newTestDaemon([]Option{WithPIDFile(path), WithNotifier(nil)}, logger)
+	assert.NoError(t, running.Start(nil))
+	defer running.Stop(nil)
+	assert.Eventually(t, func() bool { return stateOf(running, "logger").State == StateRunning }, time.Second, time.Millisecond)
+	if runtime.GOOS == "windows" {
+		assert.Error(t, c.reload("windows-service"))
+		return
+	}
+	assert.NoError(t, c.reload("windows-service"))
+	assert.Eventually(t, func() bool { return logger.reloads.Load() == 1 }, time.Second, time.Millisecond)
+}
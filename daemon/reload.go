@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/kardianos/service"
+)
+
+// Reloader is implemented by services that can apply a new configuration, such as a changed log level
+// or rate limit, without being restarted. Reload is called when the daemon receives SIGHUP,
+// or when Daemon.Reload is called, while the service is running.
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// WithReloadSignal returns an Option that sets whether the daemon reloads its services on SIGHUP.
+// It is enabled by default on platforms other than Windows. The signal is only handled if at least
+// one service implements Reloader; otherwise SIGHUP keeps its default behavior.
+func WithReloadSignal(enabled bool) Option {
+	return func(d *Daemon) {
+		d.reloadSignal = enabled
+	}
+}
+
+// Reload calls Reload on the running services implementing Reloader, in dependency order. Once systemd
+// has been told that the daemon is ready, it also tells systemd that the daemon is reloading.
+// It returns the errors of the services that failed to reload, which are also passed to their
+// HandleError method and recorded in their status.
+func (d *Daemon) Reload(ctx context.Context) error {
+	d.mu.Lock()
+	running := d.cancel != nil
+	d.mu.Unlock()
+	if !running {
+		return errors.New("daemon is not running")
+	}
+
+	// Reloads from signals and callers run one at a time, so a service is never reloaded concurrently.
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	slog.Info("Reloading services.")
+	// Before startup has finished, READY=1 is left to supervise, which sends it once all services are ready.
+	if d.ready.Load() {
+		d.notify(NotifyReloading)
+		defer d.notify(NotifyReady)
+	}
+
+	var errs []error
+	for _, s := range d.order {
+		reloader, ok := s.(Reloader)
+		if !ok || d.status.get(s.Name()).State != StateRunning {
+			continue
+		}
+		err := reloader.Reload(ctx)
+		d.status.reloaded(s.Name(), err)
+		if err != nil {
+			err = fmt.Errorf("service %s failed to reload: %w", s.Name(), err)
+			s.HandleError(err)
+			slog.Error("Daemon service reload error", "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info(fmt.Sprintf("Service %s reloaded.", s.Name()))
+	}
+	return errors.Join(errs...)
+}
+
+// reloadsOnSignal reports whether the daemon reloads its services on a reload signal: it is enabled,
+// the platform has one and at least one service implements Reloader.
+func (d *Daemon) reloadsOnSignal() bool {
+	if !d.reloadSignal || len(reloadSignals) == 0 {
+		return false
+	}
+	return slices.ContainsFunc(d.services, func(s Service) bool {
+		_, ok := s.(Reloader)
+		return ok
+	})
+}
+
+// handleReloadSignals reloads the services whenever a reload signal is received, until ctx is cancelled.
+func (d *Daemon) handleReloadSignals(ctx context.Context) {
+	if !d.reloadsOnSignal() {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, reloadSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-signals:
+				// The errors are reported by Reload.
+				_ = d.Reload(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// runCommand runs an external command, returning its output in the error if it fails.
+var runCommand = func(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Reload asks the running daemon to reload its services by sending it SIGHUP.
+// If a PID file is set with WithPIDFile, the signal is sent to the process it names, which also works
+// for a daemon running in the foreground. Otherwise, on linux-systemd, the signal is sent with systemctl.
+func (d *Controller) Reload() error {
+	slog.Info(fmt.Sprintf("Reloading service: %s", d.service.String()))
+	if err := d.reload(service.Platform()); err != nil {
+		return fmt.Errorf("failed to reload service: %w", err)
+	}
+	slog.Info(fmt.Sprintf("Service %s reloaded successfully.", d.service.String()))
+	return nil
+}
+
+// reload sends the reload signal to the service on the given platform.
+func (d *Controller) reload(platform string) error {
+	if d.daemon.pidPath != "" {
+		pid, running, err := d.PID()
+		if err != nil {
+			return err
+		}
+		if !running {
+			return fmt.Errorf("daemon is not running (PID file %s)", d.daemon.pidPath)
+		}
+		return signalReload(pid)
+	}
+	if platform != "linux-systemd" {
+		return fmt.Errorf("reload is not supported on platform %s", platform)
+	}
+	args := []string{"kill", "--signal=HUP", "--kill-whom=main", d.daemon.config.Name + ".service"}
+	if user, _ := d.daemon.config.Option["UserService"].(bool); user {
+		args = append([]string{"--user"}, args...)
+	}
+	return runCommand("systemctl", args...)
+}
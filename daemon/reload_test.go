@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// reloadService is a testService implementing Reloader.
+type reloadService struct {
+	*testService
+	reloads atomic.Int32
+	err     error
+}
+
+func (s *reloadService) Reload(ctx context.Context) error {
+	s.reloads.Add(1)
+	return s.err
+}
+
+func TestDaemon_Reload(t *testing.T) {
+	path, messages := listenNotify(t)
+	logger := &reloadService{testService: &testService{name: "logger", run: blocking}}
+	limiter := &reloadService{testService: &testService{name: "limiter", run: blocking}, err: errors.New("invalid rate")}
+	plain := &testService{name: "plain", run: blocking}
+
+	d := newTestDaemon([]Option{WithNotifier(NewNotifier(path)), WithReloadSignal(false)}, logger, limiter, plain)
+	assert.Error(t, d.Reload(context.Background()))
+
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+	assert.Equal(t, NotifyReady, receive(t, messages))
+
+	err := d.Reload(context.Background())
+	assert.EqualError(t, err, "service limiter failed to reload: invalid rate")
+	assert.Equal(t, int32(1), logger.reloads.Load())
+	assert.Equal(t, int32(1), limiter.reloads.Load())
+	assert.Equal(t, int32(1), limiter.errs.Load())
+	assert.Equal(t, NotifyReloading, receive(t, messages))
+	assert.Equal(t, NotifyReady, receive(t, messages))
+
+	assert.False(t, stateOf(d, "logger").ReloadedAt.IsZero())
+	assert.Empty(t, stateOf(d, "logger").ReloadError)
+	assert.Equal(t, "invalid rate", stateOf(d, "limiter").ReloadError)
+	assert.True(t, stateOf(d, "plain").ReloadedAt.IsZero())
+}
+
+func TestDaemon_ReloadBeforeReady(t *testing.T) {
+	path, messages := listenNotify(t)
+	release := make(chan struct{})
+	slow := depService{testService: &testService{name: "slow"}, signals: true}
+	slow.run = func(ctx context.Context) error {
+		<-release
+		Ready(ctx)
+		return blocking(ctx)
+	}
+	logger := &reloadService{testService: &testService{name: "logger", run: blocking}}
+
+	d := newTestDaemon([]Option{WithNotifier(NewNotifier(path)), WithReloadSignal(false)}, slow, logger)
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+
+	// A reload during startup doesn't tell systemd that the daemon is ready.
+	assert.NoError(t, d.Reload(context.Background()))
+	select {
+	case m := <-messages:
+		t.Fatalf("unexpected notification %q", m)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, NotifyReady, receive(t, messages))
+	assert.NoError(t, d.Reload(context.Background()))
+	assert.Equal(t, NotifyReloading, receive(t, messages))
+	assert.Equal(t, NotifyReady, receive(t, messages))
+}
+
+// slowReloadService is a testService whose Reload records the number of reloads running at the same time.
+type slowReloadService struct {
+	*testService
+	running, maxRunning atomic.Int32
+}
+
+func (s *slowReloadService) Reload(ctx context.Context) error {
+	n := s.running.Add(1)
+	defer s.running.Add(-1)
+	for m := s.maxRunning.Load(); n > m && !s.maxRunning.CompareAndSwap(m, n); m = s.maxRunning.Load() {
+	}
+	time.Sleep(5 * time.Millisecond)
+	return nil
+}
+
+func TestDaemon_ReloadSerialized(t *testing.T) {
+	svc := &slowReloadService{testService: &testService{name: "config", run: blocking}}
+	d := newTestDaemon([]Option{WithNotifier(nil), WithReloadSignal(false)}, svc)
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+	assert.Eventually(t, func() bool { return stateOf(d, "config").State == StateRunning }, time.Second, time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, d.Reload(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), svc.maxRunning.Load())
+}
+
+func TestController_Reload(t *testing.T) {
+	var commands []string
+	original := runCommand
+	defer func() { runCommand = original }()
+	runCommand = func(name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil
+	}
+
+	d := newTestDaemon(nil)
+	d.config = &service.Config{Name: "app", Option: service.KeyValue{"UserService": true}}
+	c := &Controller{daemon: d}
+	assert.NoError(t, c.reload("linux-systemd"))
+	assert.Equal(t, []string{"systemctl --user kill --signal=HUP --kill-whom=main app.service"}, commands)
+	assert.Error(t, c.reload("windows-service"))
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RestartPolicy determines whether a service is restarted after its Run method returns.
+type RestartPolicy int
+
+const (
+	// RestartNever leaves the service stopped once Run returns. It is the default.
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restarts the service when Run returns an error.
+	RestartOnFailure
+	// RestartAlways restarts the service whenever Run returns, with or without an error.
+	RestartAlways
+)
+
+// String returns the name of the policy.
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// DefaultInitialBackoff is the delay before the first restart if RestartConfig.InitialBackoff is not set.
+	DefaultInitialBackoff = time.Second
+	// DefaultMaxBackoff is the maximum delay between restarts if RestartConfig.MaxBackoff is not set.
+	DefaultMaxBackoff = time.Minute
+)
+
+// RestartConfig configures how a service is restarted after its Run method returns.
+//
+// The delay before a restart doubles with every restart within Window, starting at InitialBackoff
+// and capped at MaxBackoff, and is randomized by Jitter. Once a service has been restarted MaxRestarts
+// times within Window, it is given up on: it stays stopped, the escalation hook set with WithEscalation
+// is called and, if the service is Critical, the whole daemon is stopped.
+type RestartConfig struct {
+	Policy         RestartPolicy // 重启策略
+	InitialBackoff time.Duration // 第一次重启前的等待时间, 为 0 时使用 DefaultInitialBackoff
+	MaxBackoff     time.Duration // 重启前的最大等待时间, 为 0 时使用 DefaultMaxBackoff
+	Jitter         float64       // 等待时间的随机浮动比例 (例如 0.2 表示 ±20%)
+	MaxRestarts    int           // Window 内允许的最大重启次数, 为 0 时不限制
+	Window         time.Duration // 统计重启次数的时间窗口, 为 0 时统计全部重启
+	// Critical marks a service the daemon can't run without. When it is given up on, or fails
+	// with RestartNever, the daemon stops all services and exits with a non-zero status, so the
+	// service manager can restart the whole process.
+	Critical bool
+}
+
+// shouldRestart reports whether a service whose Run method returned err is restarted.
+func (c RestartConfig) shouldRestart(err error) bool {
+	switch c.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// backoff returns the delay before the nth restart within the window, starting at 1.
+func (c RestartConfig) backoff(n int) time.Duration {
+	initial, limit := c.InitialBackoff, c.MaxBackoff
+	if initial <= 0 {
+		initial = DefaultInitialBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+
+	delay := initial
+	for i := 1; i < n && delay < limit; i++ {
+		delay *= 2
+	}
+	delay = min(delay, limit)
+	if c.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * c.Jitter * float64(delay))
+	}
+	return max(delay, 0)
+}
+
+// restartTracker counts the restarts of a service within the window of its RestartConfig.
+type restartTracker struct {
+	config   RestartConfig
+	restarts []time.Time // 窗口内的重启时间
+}
+
+// next records a restart at now and returns the number of restarts within the window,
+// including this one. It returns false if the restart exceeds MaxRestarts.
+func (t *restartTracker) next(now time.Time) (int, bool) {
+	if t.config.Window > 0 {
+		i := 0
+		for i < len(t.restarts) && now.Sub(t.restarts[i]) >= t.config.Window {
+			i++
+		}
+		t.restarts = t.restarts[i:]
+	}
+	if t.config.MaxRestarts > 0 && len(t.restarts) >= t.config.MaxRestarts {
+		return len(t.restarts), false
+	}
+	t.restarts = append(t.restarts, now)
+	return len(t.restarts), true
+}
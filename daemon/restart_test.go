@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartConfig_Backoff(t *testing.T) {
+	config := RestartConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 100*time.Millisecond, config.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, config.backoff(2))
+	assert.Equal(t, 800*time.Millisecond, config.backoff(4))
+	assert.Equal(t, time.Second, config.backoff(5))
+	assert.Equal(t, time.Second, config.backoff(100))
+
+	// Defaults apply to unset durations.
+	assert.Equal(t, DefaultInitialBackoff, RestartConfig{}.backoff(1))
+	assert.Equal(t, DefaultMaxBackoff, RestartConfig{}.backoff(100))
+
+	config.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := config.backoff(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 300*time.Millisecond)
+	}
+}
+
+func TestRestartConfig_ShouldRestart(t *testing.T) {
+	failure := errors.New("boom")
+	assert.False(t, RestartConfig{Policy: RestartNever}.shouldRestart(failure))
+	assert.True(t, RestartConfig{Policy: RestartOnFailure}.shouldRestart(failure))
+	assert.False(t, RestartConfig{Policy: RestartOnFailure}.shouldRestart(nil))
+	assert.True(t, RestartConfig{Policy: RestartAlways}.shouldRestart(nil))
+	assert.Equal(t, "on-failure", RestartOnFailure.String())
+}
+
+func TestRestartTracker(t *testing.T) {
+	tracker := &restartTracker{config: RestartConfig{MaxRestarts: 2, Window: time.Minute}}
+	now := time.Now()
+	n, ok := tracker.next(now)
+	assert.True(t, ok)
+	assert.Equal(t, 1, n)
+	n, ok = tracker.next(now.Add(10 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 2, n)
+	_, ok = tracker.next(now.Add(20 * time.Second))
+	assert.False(t, ok)
+
+	// Restarts older than the window no longer count.
+	n, ok = tracker.next(now.Add(65 * time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, 2, n)
+}
+
+func TestDaemon_Restart(t *testing.T) {
+	var runs atomic.Int32
+	s := &testService{name: "flaky", run: func(ctx context.Context) error {
+		if runs.Add(1) < 3 {
+			return errors.New("boom")
+		}
+		return blocking(ctx)
+	}}
+
+	d := newTestDaemon([]Option{WithRestart("flaky", RestartConfig{
+		Policy:         RestartOnFailure,
+		InitialBackoff: time.Millisecond,
+		MaxRestarts:    5,
+	})}, s)
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool { return runs.Load() == 3 }, time.Second, time.Millisecond)
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, int32(2), s.errs.Load())
+	assert.Equal(t, int32(3), runs.Load())
+}
+
+func TestDaemon_RestartAlways(t *testing.T) {
+	var runs atomic.Int32
+	s := &testService{name: "oneshot", run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+
+	d := newTestDaemon([]Option{WithDefaultRestart(RestartConfig{
+		Policy:         RestartAlways,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	})}, s)
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool { return runs.Load() >= 5 }, time.Second, time.Millisecond)
+	assert.NoError(t, d.Stop(nil))
+	assert.Equal(t, int32(0), s.errs.Load())
+}
+
+func TestDaemon_Escalation(t *testing.T) {
+	failure := errors.New("boom")
+	var runs atomic.Int32
+	flaky := &testService{name: "flaky", run: func(ctx context.Context) error {
+		runs.Add(1)
+		return failure
+	}}
+	other := &testService{name: "other", run: blocking}
+
+	escalated := make(chan string, 1)
+	exited := make(chan int, 1)
+	d := newTestDaemon([]Option{
+		WithRestart("flaky", RestartConfig{
+			Policy:         RestartOnFailure,
+			InitialBackoff: time.Millisecond,
+			MaxRestarts:    2,
+			Window:         time.Minute,
+			Critical:       true,
+		}),
+		WithEscalation(func(name string, err error) {
+			assert.Equal(t, failure, err)
+			escalated <- name
+		}),
+	}, flaky, other)
+	d.exit = func(code int) { exited <- code }
+
+	assert.NoError(t, d.Start(nil))
+	assert.Equal(t, "flaky", <-escalated)
+	// The critical service stops the whole daemon and exits with a failure status.
+	assert.Equal(t, 1, <-exited)
+	assert.Equal(t, int32(3), runs.Load())
+	assert.NoError(t, d.Start(nil))
+	assert.NoError(t, d.Stop(nil))
+}
+
+func TestDaemon_EscalationNotCritical(t *testing.T) {
+	escalated := make(chan string, 1)
+	failing := &testService{name: "failing", run: func(ctx context.Context) error {
+		return errors.New("boom")
+	}}
+	other := &testService{name: "other", run: blocking}
+
+	d := newTestDaemon([]Option{
+		WithRestart("failing", RestartConfig{Policy: RestartOnFailure, InitialBackoff: time.Millisecond, MaxRestarts: 1}),
+		WithEscalation(func(name string, err error) { escalated <- name }),
+	}, failing, other)
+	d.exit = func(code int) { t.Errorf("unexpected exit with status %d", code) }
+
+	assert.NoError(t, d.Start(nil))
+	assert.Equal(t, "failing", <-escalated)
+	// The daemon keeps running the other services.
+	assert.ErrorContains(t, d.Start(nil), "already running")
+	assert.NoError(t, d.Stop(nil))
+}
@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule determines when a job runs.
+type Schedule interface {
+	// Next returns the first activation time after t, or the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule activates at a fixed interval.
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Every returns a Schedule activating every interval, counted from the previous activation.
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule{interval: interval}
+}
+
+// Next returns t plus the interval.
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// String returns the schedule in the "@every" form accepted by ParseCron.
+func (s intervalSchedule) String() string {
+	return "@every " + s.interval.String()
+}
+
+// cronSchedule is a parsed cron expression. Each field is a bit set of the allowed values.
+type cronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// cronField describes a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // 取值的英文缩写, 从 min 开始
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12,
+		names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dowField = cronField{name: "day of week", min: 0, max: 7,
+		names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// cronDescriptors are the predefined schedules accepted by ParseCron.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a standard cron expression with five fields: minute, hour, day of month, month and day of week.
+// Fields accept *, values, ranges (1-5), lists (1,15,30) and steps (*/10, 0-30/5); months and days of week
+// also accept English abbreviations (JAN, MON), and both 0 and 7 are Sunday. As in cron, when both the day
+// of month and the day of week are restricted, a day matching either one is activated. The predefined
+// schedules @yearly, @monthly, @weekly, @daily, @hourly and "@every <duration>" are accepted as well.
+// Activation times are computed in the location of the time passed to Next.
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid interval in cron expression %q", expr)
+		}
+		return Every(interval), nil
+	}
+	spec := expr
+	if strings.HasPrefix(expr, "@") {
+		var ok bool
+		if spec, ok = cronDescriptors[expr]; !ok {
+			return nil, fmt.Errorf("unknown cron descriptor %q", expr)
+		}
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expr, len(fields))
+	}
+	s := &cronSchedule{expr: expr}
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// 7 is Sunday, like 0.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// MustParseCron is like ParseCron but panics if the expression is invalid.
+func MustParseCron(expr string) Schedule {
+	s, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parse parses a field of a cron expression into a bit set.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field %q", stepText, f.name, field)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case rng == "*":
+			lo, hi = f.min, f.max
+			if f.max == 7 {
+				hi = 6 // Sunday is 0.
+			}
+		case strings.Contains(rng, "-"):
+			loText, hiText, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(loText); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(hiText); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				// "a/n" means from a to the maximum in steps of n.
+				hi = f.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single value of the field, a number or an English abbreviation.
+func (f cronField) value(text string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, must be between %d and %d", text, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching the expression, or the zero time if there is none
+// within five years (e.g. for February 30th).
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the day of month and day of week fields.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// String returns the cron expression.
+func (s *cronSchedule) String() string {
+	return s.expr
+}
@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * MON-FRI", time.Date(2024, 1, 31, 13, 30, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2024, 1, 31, 10, 25, 0, 0, time.UTC)},
+		// The day of month or the day of week matches.
+		{"0 0 13 * FRI", time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if assert.NoError(t, err, tt.expr) {
+			assert.Equal(t, tt.want, schedule.Next(base), tt.expr)
+		}
+	}
+
+	// Impossible dates never activate.
+	assert.True(t, MustParseCron("0 0 30 2 *").Next(base).IsZero())
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"5-1 * * * *", "*/0 * * * *", "* * * * FOO", "@often", "@every -1s"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+	assert.Panics(t, func() { MustParseCron("bad") })
+}
+
+func TestEvery(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC)
+	assert.Equal(t, base.Add(time.Minute), Every(time.Minute).Next(base))
+	assert.Equal(t, "@every 1m0s", Every(time.Minute).(interface{ String() string }).String())
+	assert.Equal(t, "@daily", MustParseCron("@daily").(interface{ String() string }).String())
+}
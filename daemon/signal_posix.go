@@ -0,0 +1,16 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals are the signals turned into a reload of the daemon.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// signalReload sends the reload signal to the process with the given PID.
+func signalReload(pid int) error {
+	return syscall.Kill(pid, syscall.SIGHUP)
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemon_ReloadSignal(t *testing.T) {
+	logger := &reloadService{testService: &testService{name: "logger", run: blocking}}
+	d := newTestDaemon([]Option{WithNotifier(nil)}, logger)
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool { return stateOf(d, "logger").State == StateRunning }, time.Second, time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool { return logger.reloads.Load() == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, d.Stop(nil))
+}
+
+func TestDaemon_ApplySystemdReloadSignal(t *testing.T) {
+	// "systemctl reload" sends SIGHUP if a service can reload.
+	logger := &reloadService{testService: &testService{name: "logger", run: blocking}}
+	d := newTestDaemon(nil, logger)
+	assert.True(t, d.reloadsOnSignal())
+	d.applySystemd()
+	assert.Equal(t, "HUP", d.config.Option["ReloadSignal"])
+
+	// The caller's ReloadSignal is kept.
+	d = newTestDaemon(nil, logger)
+	d.config.Option = service.KeyValue{"ReloadSignal": "USR1"}
+	d.applySystemd()
+	assert.Equal(t, "USR1", d.config.Option["ReloadSignal"])
+
+	// Without a Reloader, or with the signal disabled, SIGHUP is neither handled nor sent.
+	for _, d := range []*Daemon{
+		newTestDaemon(nil, &testService{name: "plain", run: blocking}),
+		newTestDaemon([]Option{WithReloadSignal(false)}, logger),
+	} {
+		assert.False(t, d.reloadsOnSignal())
+		d.applySystemd()
+		assert.NotContains(t, d.config.Option, "ReloadSignal")
+	}
+}
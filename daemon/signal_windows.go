@@ -0,0 +1,14 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+)
+
+// reloadSignals are the signals turned into a reload of the daemon. Windows has no reload signal.
+var reloadSignals []os.Signal
+
+// signalReload reports that reload signals are not supported on Windows.
+func signalReload(_ int) error {
+	return errors.New("reload signals are not supported on windows")
+}
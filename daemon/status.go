@@ -0,0 +1,305 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHealthTimeout is the default timeout of the health checks run by CheckHealth and Handler.
+var DefaultHealthTimeout = 5 * time.Second
+
+// State is the lifecycle state of a service managed by a Daemon.
+type State int
+
+const (
+	// StateStopped means the service is not running: the daemon is stopped, or Run returned
+	// without an error and the service is not restarted.
+	StateStopped State = iota
+	// StateStarting means the service waits for its dependencies, or runs but hasn't signalled
+	// that it is ready (see ReadySignaler).
+	StateStarting
+	// StateRunning means the Run method of the service is executing and the service is ready.
+	StateRunning
+	// StateBackoff means Run returned and the service waits to be restarted.
+	StateBackoff
+	// StateFailed means Run returned an error and the service is not restarted.
+	StateFailed
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateBackoff:
+		return "backoff"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so states are written by name in JSON.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// HealthChecker is implemented by services that can check their own health, such as
+// a service that depends on a database connection. CheckHealth returns nil if the service is healthy.
+// It is only called while the service is running and should return promptly once ctx is done.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// DetailReporter is implemented by services that add details to their status, such as the Scheduler
+// reporting the runs of its jobs. The details are written to JSON, so they should be marshalable.
+type DetailReporter interface {
+	StatusDetails() any
+}
+
+// ServiceStatus is the status of a service managed by a Daemon.
+type ServiceStatus struct {
+	Name        string    `json:"name"`
+	State       State     `json:"state"`
+	Since       time.Time `json:"since,omitzero"`         // Since is when the service entered its state.
+	Restarts    int       `json:"restarts"`               // Restarts counts the restarts since the daemon started.
+	LastError   string    `json:"last_error,omitempty"`   // LastError is the last error returned by Run.
+	LastErrorAt time.Time `json:"last_error_at,omitzero"` // LastErrorAt is when Run returned LastError.
+	// Healthy is the result of the health check, set by CheckHealth for running services
+	// implementing HealthChecker. HealthError holds the error returned by the check.
+	Healthy     *bool  `json:"healthy,omitempty"`
+	HealthError string `json:"health_error,omitempty"`
+	// ReloadedAt is when the service last reloaded, see Reloader. ReloadError holds the error it returned.
+	ReloadedAt  time.Time `json:"reloaded_at,omitzero"`
+	ReloadError string    `json:"reload_error,omitempty"`
+	// Details holds the details reported by services implementing DetailReporter.
+	Details any `json:"details,omitempty"`
+}
+
+// Status is a snapshot of the status of a Daemon and its services.
+type Status struct {
+	Running  bool            `json:"running"`
+	Services []ServiceStatus `json:"services"`
+}
+
+// Live reports whether the daemon is alive: no service has failed without being restarted.
+// A daemon that is not live should be restarted.
+func (s Status) Live() bool {
+	for _, svc := range s.Services {
+		if svc.State == StateFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Healthy reports whether the daemon is live and no health check failed.
+func (s Status) Healthy() bool {
+	if !s.Live() {
+		return false
+	}
+	for _, svc := range s.Services {
+		if svc.Healthy != nil && !*svc.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether the daemon is ready to serve: it is running, no service is starting,
+// waiting to be restarted or failed, and no health check failed.
+func (s Status) Ready() bool {
+	if !s.Running {
+		return false
+	}
+	for _, svc := range s.Services {
+		switch svc.State {
+		case StateStarting, StateBackoff, StateFailed:
+			return false
+		}
+		if svc.Healthy != nil && !*svc.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// statusTracker records the status of the services of a Daemon.
+type statusTracker struct {
+	mu       sync.Mutex
+	statuses []ServiceStatus // 与 Daemon.services 顺序一致
+	index    map[string]int  // 服务名称到 statuses 下标的映射
+}
+
+func newStatusTracker(services []Service) *statusTracker {
+	t := &statusTracker{
+		statuses: make([]ServiceStatus, len(services)),
+		index:    make(map[string]int, len(services)),
+	}
+	for i, svc := range services {
+		t.statuses[i] = ServiceStatus{Name: svc.Name(), State: StateStopped}
+		t.index[svc.Name()] = i
+	}
+	return t
+}
+
+// reset clears the restart counts when the daemon starts.
+func (t *statusTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.statuses {
+		t.statuses[i].Restarts = 0
+	}
+}
+
+// set records that the service with the given name entered state.
+// A non-nil err is recorded as its last error.
+func (t *statusTracker) set(name string, state State, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, ok := t.index[name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	status := &t.statuses[i]
+	if state == StateStarting && status.State == StateBackoff {
+		status.Restarts++
+	}
+	status.State = state
+	status.Since = now
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = now
+	}
+}
+
+// ready records that the service with the given name is running, if it is starting.
+func (t *statusTracker) ready(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[name]; ok && t.statuses[i].State == StateStarting {
+		t.statuses[i].State = StateRunning
+		t.statuses[i].Since = time.Now()
+	}
+}
+
+// reloaded records that the service with the given name reloaded, returning err.
+func (t *statusTracker) reloaded(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[name]; ok {
+		t.statuses[i].ReloadedAt = time.Now()
+		t.statuses[i].ReloadError = ""
+		if err != nil {
+			t.statuses[i].ReloadError = err.Error()
+		}
+	}
+}
+
+// get returns the status of the service with the given name.
+func (t *statusTracker) get(name string) ServiceStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[name]; ok {
+		return t.statuses[i]
+	}
+	return ServiceStatus{}
+}
+
+// snapshot returns a copy of the statuses.
+func (t *statusTracker) snapshot() []ServiceStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	statuses := make([]ServiceStatus, len(t.statuses))
+	copy(statuses, t.statuses)
+	return statuses
+}
+
+// Status returns a snapshot of the status of the daemon and its services. It doesn't run health checks.
+func (d *Daemon) Status() Status {
+	d.mu.Lock()
+	running := d.cancel != nil
+	d.mu.Unlock()
+	status := Status{Running: running, Services: d.status.snapshot()}
+	for i, svc := range d.services {
+		if reporter, ok := svc.(DetailReporter); ok {
+			status.Services[i].Details = reporter.StatusDetails()
+		}
+	}
+	return status
+}
+
+// CheckHealth returns a snapshot of the status of the daemon and its services, including the result
+// of the health checks of the running services implementing HealthChecker. The checks run concurrently.
+func (d *Daemon) CheckHealth(ctx context.Context) Status {
+	status := d.Status()
+	var wg sync.WaitGroup
+	for i, svc := range d.services {
+		checker, ok := svc.(HealthChecker)
+		if !ok || status.Services[i].State != StateRunning {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := checker.CheckHealth(ctx)
+			healthy := err == nil
+			status.Services[i].Healthy = &healthy
+			if err != nil {
+				status.Services[i].HealthError = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return status
+}
+
+// Handler returns an HTTP handler exposing the status of the daemon, for load balancers and operators:
+//
+//   - /livez responds with 200 if the daemon is live (see Status.Live) and 503 otherwise;
+//   - /readyz runs the health checks and responds with 200 if the daemon is ready (see Status.Ready) and 503 otherwise;
+//   - /status runs the health checks and responds with 200.
+//
+// All endpoints write the status as JSON. Use http.StripPrefix to mount the handler under a path.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, r *http.Request) {
+		status := d.Status()
+		writeStatus(w, status, status.Live())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := d.checkHealth(r.Context())
+		writeStatus(w, status, status.Ready())
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, d.checkHealth(r.Context()), true)
+	})
+	return mux
+}
+
+// checkHealth runs CheckHealth with the health timeout.
+func (d *Daemon) checkHealth(ctx context.Context) Status {
+	ctx, cancel := context.WithTimeout(ctx, d.healthTimeout)
+	defer cancel()
+	return d.CheckHealth(ctx)
+}
+
+// writeStatus writes status as JSON, with status code 200 if ok and 503 otherwise.
+func writeStatus(w http.ResponseWriter, status Status, ok bool) {
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
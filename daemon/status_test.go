@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// healthService is a testService implementing HealthChecker.
+type healthService struct {
+	*testService
+	unhealthy atomic.Bool
+}
+
+func (s *healthService) CheckHealth(ctx context.Context) error {
+	if s.unhealthy.Load() {
+		return errors.New("database unreachable")
+	}
+	return nil
+}
+
+// stateOf returns the status of the service with the given name.
+func stateOf(d *Daemon, name string) ServiceStatus {
+	for _, status := range d.Status().Services {
+		if status.Name == name {
+			return status
+		}
+	}
+	return ServiceStatus{}
+}
+
+func TestDaemon_Status(t *testing.T) {
+	var runs atomic.Int32
+	flaky := &testService{name: "flaky", run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("boom")
+		}
+		return blocking(ctx)
+	}}
+	failing := &testService{name: "failing", run: func(ctx context.Context) error {
+		return errors.New("fatal")
+	}}
+	oneshot := &testService{name: "oneshot", run: func(ctx context.Context) error { return nil }}
+
+	d := newTestDaemon([]Option{
+		WithRestart("flaky", RestartConfig{Policy: RestartOnFailure, InitialBackoff: time.Millisecond}),
+	}, flaky, failing, oneshot)
+	status := d.Status()
+	assert.False(t, status.Running)
+	assert.Equal(t, StateStopped, status.Services[0].State)
+
+	assert.NoError(t, d.Start(nil))
+	assert.Eventually(t, func() bool {
+		return stateOf(d, "flaky").State == StateRunning && runs.Load() == 2 &&
+			stateOf(d, "failing").State == StateFailed && stateOf(d, "oneshot").State == StateStopped
+	}, time.Second, time.Millisecond)
+
+	status = d.Status()
+	assert.True(t, status.Running)
+	assert.Equal(t, 1, status.Services[0].Restarts)
+	assert.Equal(t, "boom", status.Services[0].LastError)
+	assert.False(t, status.Services[0].LastErrorAt.IsZero())
+	assert.Equal(t, "fatal", status.Services[1].LastError)
+	assert.False(t, status.Live())
+	assert.False(t, status.Ready())
+
+	assert.NoError(t, d.Stop(nil))
+	status = d.Status()
+	assert.False(t, status.Running)
+	assert.Equal(t, StateStopped, status.Services[0].State)
+	assert.Equal(t, StateFailed, status.Services[1].State)
+}
+
+func TestDaemon_Handler(t *testing.T) {
+	db := &healthService{testService: &testService{name: "db", run: blocking}}
+	d := newTestDaemon([]Option{WithHealthTimeout(time.Second)}, db)
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	// The daemon is alive but not ready before it is started.
+	code, body := getJSON(t, server.URL+"/livez")
+	assert.Equal(t, false, body["running"])
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = getJSON(t, server.URL+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	assert.NoError(t, d.Start(nil))
+	defer d.Stop(nil)
+	assert.Eventually(t, func() bool { return stateOf(d, "db").State == StateRunning }, time.Second, time.Millisecond)
+	code, body = getJSON(t, server.URL+"/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, body["running"])
+	service := body["services"].([]any)[0].(map[string]any)
+	assert.Equal(t, "db", service["name"])
+	assert.Equal(t, "running", service["state"])
+	assert.Equal(t, true, service["healthy"])
+
+	// A failing health check makes the daemon unready, but not dead.
+	db.unhealthy.Store(true)
+	code, _ = getJSON(t, server.URL+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = getJSON(t, server.URL+"/livez")
+	assert.Equal(t, http.StatusOK, code)
+	code, body = getJSON(t, server.URL+"/status")
+	assert.Equal(t, http.StatusOK, code)
+	service = body["services"].([]any)[0].(map[string]any)
+	assert.Equal(t, false, service["healthy"])
+	assert.Equal(t, "database unreachable", service["health_error"])
+}
+
+// getJSON sends a GET request to url and decodes the JSON response.
+func getJSON(t *testing.T, url string) (int, map[string]any) {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var body map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
@@ -0,0 +1,188 @@
+package daemon
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kardianos/service"
+)
+
+// DefaultSystemdUnit is the unit configuration applied by NewController on linux-systemd
+// unless WithSystemdUnit is used.
+var DefaultSystemdUnit = SystemdUnit{
+	LimitNOFILE: 40960, // Set a higher limit for open file descriptors.
+}
+
+// SystemdUnit describes the settings of the systemd unit installed for the daemon.
+// Zero fields are not set. Apply merges it into a service.Config.
+type SystemdUnit struct {
+	// [Unit] section.
+	After    []string // 在这些单元之后启动, 例如 "network-online.target"
+	Wants    []string // 弱依赖的单元
+	Requires []string // 强依赖的单元
+
+	// [Service] section.
+	Notify            bool              // 使用 Type=notify, 服务就绪后才视为启动完成 (见 Notifier)
+	WatchdogSec       time.Duration     // 看门狗超时时间, 需要 Notify
+	User              string            // 运行服务的用户
+	Group             string            // 运行服务的用户组
+	WorkingDirectory  string            // 工作目录
+	Environment       map[string]string // 环境变量
+	LimitNOFILE       int               // 打开文件数限制
+	Restart           string            // 重启策略: "no", "on-failure", "always" 等, 默认 "always"
+	RestartSec        time.Duration     // 重启前的等待时间, 默认 120 秒
+	SuccessExitStatus string            // 视为成功的额外退出码或信号, 例如 "143 SIGTERM"
+	TimeoutStopSec    time.Duration     // 停止服务的超时时间, 应大于守护进程的停止超时
+	LogDirectory      string            // 标准输出和错误输出的日志目录, 设置后写入 <name>.out 和 <name>.err
+}
+
+// Apply merges the unit settings into conf. Settings the caller already made in conf, such as its
+// UserName or an Option key, are kept. Directives the default unit template of the service package
+// doesn't support (Type, WatchdogSec, Group, RestartSec and TimeoutStopSec) are added with a custom
+// SystemdScript, unless conf already sets one.
+func (u SystemdUnit) Apply(conf *service.Config) {
+	for _, dep := range u.dependencies() {
+		if !slices.Contains(conf.Dependencies, dep) {
+			conf.Dependencies = append(conf.Dependencies, dep)
+		}
+	}
+	if conf.UserName == "" {
+		conf.UserName = u.User
+	}
+	if conf.WorkingDirectory == "" {
+		conf.WorkingDirectory = u.WorkingDirectory
+	}
+	if len(u.Environment) > 0 {
+		if conf.EnvVars == nil {
+			conf.EnvVars = make(map[string]string, len(u.Environment))
+		}
+		for k, v := range u.Environment {
+			if _, ok := conf.EnvVars[k]; !ok {
+				conf.EnvVars[k] = v
+			}
+		}
+	}
+
+	options := service.KeyValue{}
+	if u.LimitNOFILE > 0 {
+		options["LimitNOFILE"] = u.LimitNOFILE
+	}
+	if u.Restart != "" {
+		options["Restart"] = u.Restart
+	}
+	if u.SuccessExitStatus != "" {
+		options["SuccessExitStatus"] = u.SuccessExitStatus
+	}
+	if u.LogDirectory != "" {
+		options["LogOutput"] = true
+		options["LogDirectory"] = u.LogDirectory
+	}
+	if extra := u.extraDirectives(); extra != "" {
+		options["SystemdScript"] = strings.Replace(systemdScript, "{{/* extra */}}", "{{"+strconv.Quote(extra)+"}}", 1)
+	}
+
+	if conf.Option == nil {
+		conf.Option = make(service.KeyValue, len(options))
+	}
+	for k, v := range options {
+		if _, ok := conf.Option[k]; !ok {
+			conf.Option[k] = v
+		}
+	}
+}
+
+// applySystemd applies the unit settings to the daemon's configuration. If the daemon reloads its
+// services on SIGHUP, the unit gets ExecReload so that "systemctl reload" sends it, unless the caller
+// set a ReloadSignal option.
+func (d *Daemon) applySystemd() {
+	d.systemd.Apply(d.config)
+	if d.reloadsOnSignal() {
+		if _, ok := d.config.Option["ReloadSignal"]; !ok {
+			d.config.Option["ReloadSignal"] = "HUP"
+		}
+	}
+}
+
+// dependencies returns the [Unit] dependency directives.
+func (u SystemdUnit) dependencies() []string {
+	var deps []string
+	for _, d := range []struct {
+		key   string
+		units []string
+	}{{"After", u.After}, {"Wants", u.Wants}, {"Requires", u.Requires}} {
+		if len(d.units) > 0 {
+			deps = append(deps, d.key+"="+strings.Join(d.units, " "))
+		}
+	}
+	return deps
+}
+
+// extraDirectives returns the [Service] directives missing from the default unit template,
+// one per line, or an empty string if there are none.
+func (u SystemdUnit) extraDirectives() string {
+	var lines []string
+	if u.Notify {
+		lines = append(lines, "Type=notify", "NotifyAccess=main")
+	}
+	if u.WatchdogSec > 0 {
+		lines = append(lines, "WatchdogSec="+seconds(u.WatchdogSec))
+	}
+	if u.Group != "" {
+		lines = append(lines, "Group="+u.Group)
+	}
+	if u.TimeoutStopSec > 0 {
+		lines = append(lines, "TimeoutStopSec="+seconds(u.TimeoutStopSec))
+	}
+	if len(lines) == 0 && u.RestartSec <= 0 {
+		return ""
+	}
+	// The default template hard-codes RestartSec=120, so the custom one always sets it.
+	restartSec := 120 * time.Second
+	if u.RestartSec > 0 {
+		restartSec = u.RestartSec
+	}
+	lines = append(lines, "RestartSec="+seconds(restartSec))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// seconds formats d as a systemd time span in seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// systemdScript is the default unit template of the service package (v1.2.4) with a placeholder
+// for the extra [Service] directives in place of its hard-coded RestartSec.
+var systemdScript = `[Unit]
+Description={{.Description}}
+ConditionFileIsExecutable={{.Path|cmdEscape}}
+{{range $i, $dep := .Dependencies}} 
+{{$dep}} {{end}}
+
+[Service]
+StartLimitInterval=5
+StartLimitBurst=10
+ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
+{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
+{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
+{{if .UserName}}User={{.UserName}}{{end}}
+{{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
+{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{if and .LogOutput .HasOutputFileSupport -}}
+StandardOutput=file:{{.LogDirectory}}/{{.Name}}.out
+StandardError=file:{{.LogDirectory}}/{{.Name}}.err
+{{- end}}
+{{if gt .LimitNOFILE -1 }}LimitNOFILE={{.LimitNOFILE}}{{end}}
+{{if .Restart}}Restart={{.Restart}}{{end}}
+{{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}{{end}}
+{{/* extra */}}
+EnvironmentFile=-/etc/sysconfig/{{.Name}}
+
+{{range $k, $v := .EnvVars -}}
+Environment={{$k}}={{$v}}
+{{end -}}
+
+[Install]
+WantedBy=multi-user.target
+`
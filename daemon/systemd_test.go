@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/kardianos/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderUnit renders the SystemdScript option of conf with the data the service package passes to it.
+func renderUnit(t *testing.T, conf *service.Config) string {
+	funcs := template.FuncMap{
+		"cmd":       func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` },
+		"cmdEscape": func(s string) string { return strings.ReplaceAll(s, " ", `\x20`) },
+	}
+	tmpl, err := template.New("").Funcs(funcs).Parse(conf.Option["SystemdScript"].(string))
+	assert.NoError(t, err)
+	option := func(key string, value any) any {
+		if v, ok := conf.Option[key]; ok {
+			return v
+		}
+		return value
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, &struct {
+		*service.Config
+		Path                 string
+		HasOutputFileSupport bool
+		ReloadSignal         string
+		PIDFile              string
+		LimitNOFILE          int
+		Restart              string
+		SuccessExitStatus    string
+		LogOutput            bool
+		LogDirectory         string
+	}{
+		conf, "/usr/bin/app", true, "", "",
+		option("LimitNOFILE", -1).(int), option("Restart", "always").(string), option("SuccessExitStatus", "").(string),
+		option("LogOutput", false).(bool), option("LogDirectory", "/var/log").(string),
+	})
+	assert.NoError(t, err)
+	return b.String()
+}
+
+func TestSystemdUnit_Apply(t *testing.T) {
+	conf := &service.Config{
+		Name:         "app",
+		UserName:     "caller",
+		Dependencies: []string{"After=network-online.target"},
+		EnvVars:      map[string]string{"MODE": "caller"},
+		Option:       service.KeyValue{"LimitNOFILE": 1024, "UserService": false},
+	}
+	SystemdUnit{
+		After:            []string{"network-online.target"},
+		Wants:            []string{"network-online.target", "postgresql.service"},
+		User:             "app",
+		WorkingDirectory: "/srv/app",
+		Environment:      map[string]string{"MODE": "unit", "LANG": "C.UTF-8"},
+		LimitNOFILE:      40960,
+		Restart:          "on-failure",
+		LogDirectory:     "/var/log/app",
+	}.Apply(conf)
+
+	// The caller's settings are kept and the unit's are added.
+	assert.Equal(t, "caller", conf.UserName)
+	assert.Equal(t, "/srv/app", conf.WorkingDirectory)
+	assert.Equal(t, []string{"After=network-online.target", "Wants=network-online.target postgresql.service"}, conf.Dependencies)
+	assert.Equal(t, map[string]string{"MODE": "caller", "LANG": "C.UTF-8"}, conf.EnvVars)
+	assert.Equal(t, service.KeyValue{
+		"LimitNOFILE":  1024,
+		"UserService":  false,
+		"Restart":      "on-failure",
+		"LogOutput":    true,
+		"LogDirectory": "/var/log/app",
+	}, conf.Option)
+
+	// A nil Option is created.
+	conf = &service.Config{Name: "app"}
+	DefaultSystemdUnit.Apply(conf)
+	assert.Equal(t, service.KeyValue{"LimitNOFILE": 40960}, conf.Option)
+}
+
+func TestSystemdUnit_Script(t *testing.T) {
+	conf := &service.Config{Name: "app", Dependencies: []string{"Requires=redis.service"}}
+	SystemdUnit{
+		After:          []string{"network-online.target"},
+		Notify:         true,
+		WatchdogSec:    30 * time.Second,
+		Group:          "app",
+		RestartSec:     1500 * time.Millisecond,
+		TimeoutStopSec: time.Minute,
+	}.Apply(conf)
+
+	unit := renderUnit(t, conf)
+	for _, line := range []string{
+		"Requires=redis.service",
+		"After=network-online.target",
+		"Type=notify\nNotifyAccess=main\nWatchdogSec=30\nGroup=app\nTimeoutStopSec=60\nRestartSec=1.5\n",
+		"Restart=always",
+		"ExecStart=/usr/bin/app",
+	} {
+		assert.Contains(t, unit, line)
+	}
+	assert.NotContains(t, unit, "RestartSec=120")
+
+	// A script set by the caller is kept.
+	conf = &service.Config{Name: "app", Option: service.KeyValue{"SystemdScript": "custom"}}
+	SystemdUnit{Notify: true}.Apply(conf)
+	assert.Equal(t, "custom", conf.Option["SystemdScript"])
+
+	// Without extra directives, the default template of the service package is used.
+	conf = &service.Config{Name: "app"}
+	SystemdUnit{Restart: "always"}.Apply(conf)
+	assert.NotContains(t, conf.Option, "SystemdScript")
+}
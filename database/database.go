@@ -1,12 +1,17 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -49,41 +54,233 @@ func NewDialector(driver Driver, dsn string) (gorm.Dialector, error) {
 	return dialect, nil
 }
 
+// Default connection pool settings of NewDB and NewDBWithOptions.
+const (
+	DefaultMaxIdleConns    = 50
+	DefaultConnMaxLifetime = 10 * time.Minute
+	DefaultConnMaxIdleTime = 10 * time.Minute
+	DefaultInitialBackoff  = time.Second
+	DefaultMaxBackoff      = 30 * time.Second
+)
+
+// DBOption is a function type used to configure NewDBWithOptions.
+type DBOption func(o *dbOptions)
+
+// dbOptions holds the settings of NewDBWithOptions.
+type dbOptions struct {
+	gormOptions       []gorm.Option
+	maxOpenConns      *int          // 最大打开连接数, nil 时使用默认值
+	maxIdleConns      *int          // 最大空闲连接数, nil 时使用默认值
+	connMaxLifetime   time.Duration // 连接的最长生命周期
+	connMaxIdleTime   time.Duration // 连接的最长空闲时间
+	attempts          int           // 连接尝试次数, 小于等于 0 时一直重试直到 ctx 结束
+	initialBackoff    time.Duration // 第一次重试前的等待时间
+	maxBackoff        time.Duration // 重试等待时间的上限
+	sqliteWAL         bool          // SQLite 是否使用 WAL 日志模式
+	sqliteBusyTimeout time.Duration // SQLite 等待数据库锁的超时时间
+}
+
+// WithGormOptions returns a DBOption that passes opts to gorm.Open.
+func WithGormOptions(opts ...gorm.Option) DBOption {
+	return func(o *dbOptions) {
+		o.gormOptions = append(o.gormOptions, opts...)
+	}
+}
+
+// WithMaxOpenConns returns a DBOption that sets the maximum number of open connections, see sql.DB.SetMaxOpenConns.
+// The default is 0 (unlimited), and 1 for SQLite.
+func WithMaxOpenConns(n int) DBOption {
+	return func(o *dbOptions) {
+		o.maxOpenConns = &n
+	}
+}
+
+// WithMaxIdleConns returns a DBOption that sets the maximum number of idle connections, see sql.DB.SetMaxIdleConns.
+// The default is DefaultMaxIdleConns, and 1 for SQLite.
+func WithMaxIdleConns(n int) DBOption {
+	return func(o *dbOptions) {
+		o.maxIdleConns = &n
+	}
+}
+
+// WithConnMaxLifetime returns a DBOption that sets how long a connection may be reused, see sql.DB.SetConnMaxLifetime.
+// The default is DefaultConnMaxLifetime; 0 reuses connections forever.
+func WithConnMaxLifetime(d time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.connMaxLifetime = d
+	}
+}
+
+// WithConnMaxIdleTime returns a DBOption that sets how long a connection may be idle, see sql.DB.SetConnMaxIdleTime.
+// The default is DefaultConnMaxIdleTime; 0 keeps idle connections forever.
+func WithConnMaxIdleTime(d time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.connMaxIdleTime = d
+	}
+}
+
+// WithRetry returns a DBOption that sets how often connecting is attempted and the backoff before the first retry,
+// which doubles with every further attempt up to the maximum backoff. A backoff of 0 or less uses
+// DefaultInitialBackoff. If attempts is 0 or less, connecting is retried until the context is done,
+// e.g. for containers that start before the database is up.
+// By default connecting is attempted once.
+func WithRetry(attempts int, backoff time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.attempts = attempts
+		o.initialBackoff = backoff
+	}
+}
+
+// WithMaxBackoff returns a DBOption that caps the backoff between connection attempts.
+// The default, also used for a value of 0 or less, is DefaultMaxBackoff.
+func WithMaxBackoff(d time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.maxBackoff = d
+	}
+}
+
+// WithSqliteWAL returns a DBOption that enables the write-ahead log journal mode of SQLite databases,
+// which lets readers proceed while a write is in progress. It has no effect on in-memory databases.
+func WithSqliteWAL(enabled bool) DBOption {
+	return func(o *dbOptions) {
+		o.sqliteWAL = enabled
+	}
+}
+
+// WithSqliteBusyTimeout returns a DBOption that sets how long SQLite waits for a locked database
+// before failing with SQLITE_BUSY. The default is the driver's, 5 seconds.
+func WithSqliteBusyTimeout(d time.Duration) DBOption {
+	return func(o *dbOptions) {
+		o.sqliteBusyTimeout = d
+	}
+}
+
 // NewDB initializes a new GORM database connection using the provided Dialector and options.
 // It configures connection pool settings (MaxOpenConns, MaxIdleConns, ConnMaxLifetime, ConnMaxIdleTime)
 // and pings the database to ensure connectivity.
 // For SQLite, MaxOpenConns and MaxIdleConns are set to 1.
+// Use NewDBWithOptions to change the pool settings or to retry connecting.
 func NewDB(dialector gorm.Dialector, opts ...gorm.Option) (*gorm.DB, error) {
-	conn, err := gorm.Open(dialector, opts...)
-	if err != nil {
-		return nil, err
-	}
+	return NewDBWithOptions(context.Background(), dialector, WithGormOptions(opts...))
+}
 
-	sql, err := conn.DB()
-	if err != nil {
-		return nil, err
+// NewDBWithOptions initializes a new GORM database connection using the provided Dialector and options,
+// configures the connection pool and pings the database. If connecting fails, it is retried with
+// exponential backoff as set by WithRetry, until the attempts are used up or ctx is done.
+//
+// SQLite databases default to a single open and idle connection, as an in-memory database exists only
+// within its connection. Its WAL mode and busy timeout are added to the DSN of a Dialector created by
+// NewDialector; parameters already in the DSN take precedence.
+func NewDBWithOptions(ctx context.Context, dialector gorm.Dialector, opts ...DBOption) (*gorm.DB, error) {
+	o := &dbOptions{
+		connMaxLifetime: DefaultConnMaxLifetime,
+		connMaxIdleTime: DefaultConnMaxIdleTime,
+		attempts:        1,
+		initialBackoff:  DefaultInitialBackoff,
+		maxBackoff:      DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(o)
 	}
 
 	maxOpen := 0 // Default to unlimited
-	maxIdle := 50
-
+	maxIdle := DefaultMaxIdleConns
 	if dialector.Name() == string(Sqlite) {
 		maxOpen = 1
 		maxIdle = 1
+		dialector = o.sqliteDialector(dialector)
+	}
+	if o.maxOpenConns != nil {
+		maxOpen = *o.maxOpenConns
+	}
+	if o.maxIdleConns != nil {
+		maxIdle = *o.maxIdleConns
+	}
+
+	// A zero backoff would never grow and retry an unreachable database in a tight loop.
+	if o.initialBackoff <= 0 {
+		o.initialBackoff = DefaultInitialBackoff
+	}
+	if o.maxBackoff <= 0 {
+		o.maxBackoff = DefaultMaxBackoff
 	}
 
-	sql.SetMaxOpenConns(maxOpen)
-	sql.SetMaxIdleConns(maxIdle)
+	var err error
+	backoff := min(o.initialBackoff, o.maxBackoff)
+	for attempt := 1; ; attempt++ {
+		var conn *gorm.DB
+		if conn, err = o.open(ctx, dialector, maxOpen, maxIdle); err == nil {
+			return conn, nil
+		}
+		if o.attempts > 0 && attempt >= o.attempts {
+			return nil, err
+		}
 
-	err = sql.Ping()
+		slog.Warn("Failed to connect to database, retrying", "driver", dialector.Name(),
+			"attempt", attempt, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, o.maxBackoff)
+	}
+}
+
+// open opens the database, configures its connection pool and pings it. The connection is closed if the ping fails.
+func (o *dbOptions) open(ctx context.Context, dialector gorm.Dialector, maxOpen, maxIdle int) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	conn, err := gorm.Open(dialector, o.gormOptions...)
+	if conn == nil || conn.ConnPool == nil {
+		return nil, err
+	}
+	sql, dbErr := conn.DB()
+	if dbErr != nil {
+		return nil, errors.Join(err, dbErr)
+	}
+	if err == nil {
+		sql.SetMaxOpenConns(maxOpen)
+		sql.SetMaxIdleConns(maxIdle)
+		sql.SetConnMaxLifetime(o.connMaxLifetime)
+		sql.SetConnMaxIdleTime(o.connMaxIdleTime)
+		err = sql.PingContext(ctx)
+	}
 	if err != nil {
+		_ = sql.Close()
 		return nil, err
 	}
+	return conn, nil
+}
 
-	sql.SetConnMaxLifetime(10 * time.Minute)
-	sql.SetConnMaxIdleTime(10 * time.Minute)
+// sqliteDialector returns a copy of a SQLite Dialector created by NewDialector with the WAL mode and
+// busy timeout added to its DSN. Other Dialectors are returned as they are.
+func (o *dbOptions) sqliteDialector(dialector gorm.Dialector) gorm.Dialector {
+	d, ok := dialector.(*sqlite.Dialector)
+	if !ok || d.Conn != nil {
+		return dialector
+	}
+	var params []string
+	if o.sqliteWAL {
+		params = append(params, "_journal_mode=WAL")
+	}
+	if o.sqliteBusyTimeout > 0 {
+		params = append(params, "_busy_timeout="+strconv.FormatInt(o.sqliteBusyTimeout.Milliseconds(), 10))
+	}
+	if len(params) == 0 {
+		return dialector
+	}
 
-	return conn, nil
+	sep := "?"
+	if strings.Contains(d.DSN, "?") {
+		sep = "&"
+	}
+	copied := *d
+	copied.DSN = d.DSN + sep + strings.Join(params, "&")
+	return &copied
 }
 
 // CloseDB closes the underlying sql.DB connection from a gorm.DB instance.
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// flakyDialector fails to initialize until it has been tried fails times.
+type flakyDialector struct {
+	gorm.Dialector
+	fails int
+	tries int
+}
+
+func (d *flakyDialector) Initialize(db *gorm.DB) error {
+	d.tries++
+	if d.tries <= d.fails {
+		return errors.New("database is starting")
+	}
+	return d.Dialector.Initialize(db)
+}
+
+func newFlakyDialector(t *testing.T, fails int) *flakyDialector {
+	dial, err := NewDialector(Sqlite, ":memory:")
+	if err != nil {
+		t.Fatalf("NewDialector error: %v", err)
+	}
+	return &flakyDialector{Dialector: dial, fails: fails}
+}
+
+func TestNewDBWithOptions_Pool(t *testing.T) {
+	dial, err := NewDialector(Sqlite, ":memory:")
+	if err != nil {
+		t.Fatalf("NewDialector error: %v", err)
+	}
+	db, err := NewDBWithOptions(context.Background(), dial,
+		WithMaxOpenConns(4), WithMaxIdleConns(2), WithConnMaxLifetime(time.Minute), WithConnMaxIdleTime(time.Second))
+	if err != nil {
+		t.Fatalf("NewDBWithOptions error: %v", err)
+	}
+	defer CloseDB(db)
+
+	sql, err := db.DB()
+	if err != nil {
+		t.Fatalf("DB error: %v", err)
+	}
+	if got := sql.Stats().MaxOpenConnections; got != 4 {
+		t.Errorf("MaxOpenConnections = %d, want 4", got)
+	}
+
+	// SQLite defaults to a single connection.
+	db, err = NewDB(dial)
+	if err != nil {
+		t.Fatalf("NewDB error: %v", err)
+	}
+	defer CloseDB(db)
+	sql, _ = db.DB()
+	if got := sql.Stats().MaxOpenConnections; got != 1 {
+		t.Errorf("MaxOpenConnections = %d, want 1", got)
+	}
+}
+
+func TestNewDBWithOptions_Sqlite(t *testing.T) {
+	dial, err := NewDialector(Sqlite, filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("NewDialector error: %v", err)
+	}
+	db, err := NewDBWithOptions(context.Background(), dial, WithSqliteWAL(true), WithSqliteBusyTimeout(1500*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewDBWithOptions error: %v", err)
+	}
+	defer CloseDB(db)
+
+	var mode string
+	if err := db.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil {
+		t.Fatalf("journal_mode error: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal_mode = %q, want wal", mode)
+	}
+	var timeout int
+	if err := db.Raw("PRAGMA busy_timeout").Scan(&timeout).Error; err != nil {
+		t.Fatalf("busy_timeout error: %v", err)
+	}
+	if timeout != 1500 {
+		t.Errorf("busy_timeout = %d, want 1500", timeout)
+	}
+}
+
+func TestNewDBWithOptions_Retry(t *testing.T) {
+	dial := newFlakyDialector(t, 2)
+	db, err := NewDBWithOptions(context.Background(), dial, WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewDBWithOptions error: %v", err)
+	}
+	_ = CloseDB(db)
+	if dial.tries != 3 {
+		t.Errorf("tries = %d, want 3", dial.tries)
+	}
+
+	// The last error is returned once the attempts are used up.
+	dial = newFlakyDialector(t, 5)
+	if _, err = NewDBWithOptions(context.Background(), dial, WithRetry(2, time.Millisecond)); err == nil {
+		t.Error("should return error after the last attempt")
+	}
+	if dial.tries != 2 {
+		t.Errorf("tries = %d, want 2", dial.tries)
+	}
+
+	// By default connecting is attempted once.
+	dial = newFlakyDialector(t, 1)
+	if _, err = NewDB(dial); err == nil {
+		t.Error("should return error without retry")
+	}
+}
+
+func TestNewDBWithOptions_RetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	dial := newFlakyDialector(t, 1000)
+	start := time.Now()
+	_, err := NewDBWithOptions(ctx, dial, WithRetry(0, time.Millisecond), WithMaxBackoff(10*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry took %s after the context was done", elapsed)
+	}
+	if dial.tries < 3 {
+		t.Errorf("tries = %d, want retries until the context is done", dial.tries)
+	}
+}
+
+func TestNewDBWithOptions_ZeroBackoff(t *testing.T) {
+	// A zero backoff falls back to the default instead of retrying in a tight loop.
+	for _, opts := range [][]DBOption{
+		{WithRetry(0, 0)},
+		{WithRetry(0, -time.Second)},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		dial := newFlakyDialector(t, 1000)
+		_, err := NewDBWithOptions(ctx, dial, opts...)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+		}
+		if dial.tries > 2 {
+			t.Errorf("tries = %d, want the default backoff between attempts", dial.tries)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tjfoc/gmsm/gmtls"
+	gmsm_x509 "github.com/tjfoc/gmsm/x509"
+)
+
+// NewGMTLSServerConfig creates a GM-TLS (GM/T 0024, a.k.a. TLCP) server configuration.
+// GM-TLS requires two SM2 certificates: sigCert is used for signing during the handshake
+// and encCert is used for key exchange. The order of the certificates matters.
+func NewGMTLSServerConfig(sigCert, encCert gmtls.Certificate) *gmtls.Config {
+	return &gmtls.Config{
+		GMSupport:    gmtls.NewGMSupport(),
+		Certificates: []gmtls.Certificate{sigCert, encCert},
+	}
+}
+
+// LoadGMTLSServerConfig loads the signing and encryption key pairs from PEM files
+// and creates a GM-TLS server configuration from them.
+func LoadGMTLSServerConfig(sigCertFile, sigKeyFile, encCertFile, encKeyFile string) (*gmtls.Config, error) {
+	sigCert, err := gmtls.LoadX509KeyPair(sigCertFile, sigKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GM-TLS signing key pair: %w", err)
+	}
+	encCert, err := gmtls.LoadX509KeyPair(encCertFile, encKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GM-TLS encryption key pair: %w", err)
+	}
+	return NewGMTLSServerConfig(sigCert, encCert), nil
+}
+
+// NewGMTLSClientConfig creates a GM-TLS client configuration.
+// rootCAs is the pool of SM2 root certificates used to verify the server; if nil, only
+// the built-in roots of the gmsm library are trusted. certs are optional client
+// certificates for mutual authentication (signing certificate first, then encryption certificate).
+func NewGMTLSClientConfig(rootCAs *gmsm_x509.CertPool, certs ...gmtls.Certificate) *gmtls.Config {
+	return &gmtls.Config{
+		GMSupport:    gmtls.NewGMSupport(),
+		RootCAs:      rootCAs,
+		Certificates: certs,
+	}
+}
+
+// WithTransportGMTLS returns a TransportOption that makes the transport speak GM-TLS for https requests.
+// The standard library TLS stack does not support SM2/SM4 cipher suites, so the transport dials
+// the connection itself and performs the GM-TLS handshake using the gmsm library.
+// HTTP/2 is disabled because GM-TLS does not negotiate ALPN.
+//
+// Proxies are not supported: for requests through a proxy, http.Transport performs the handshake
+// inside the CONNECT tunnel with crypto/tls, which would silently bypass GM-TLS. The option therefore
+// clears the proxy of the transport, including the default one taken from HTTPS_PROXY and related
+// environment variables, and connects directly. Don't set a proxy with a later option.
+func WithTransportGMTLS(config *gmtls.Config) TransportOption {
+	return func(transport *http.Transport) error {
+		if config == nil {
+			return errors.New("GM-TLS config is nil")
+		}
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.TLSClientConfig = nil
+		transport.ForceAttemptHTTP2 = false
+		transport.Proxy = nil
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialGMTLS(ctx, dialer, network, addr, config)
+		}
+		return nil
+	}
+}
+
+// dialGMTLS connects to addr and performs the GM-TLS client handshake.
+// The handshake is aborted when ctx is cancelled or its deadline expires.
+func dialGMTLS(ctx context.Context, dialer *net.Dialer, network, addr string, config *gmtls.Config) (net.Conn, error) {
+	rawConn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Infer the server name from the address if the caller did not set one,
+	// without modifying the shared configuration.
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	conn := gmtls.Client(rawConn, config)
+	stop := context.AfterFunc(ctx, func() {
+		_ = rawConn.Close()
+	})
+	err = conn.Handshake()
+	if !stop() && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, fmt.Errorf("GM-TLS handshake with %s failed: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// ServeGMTLS accepts connections on listener, wraps them in GM-TLS using config and
+// serves them with server. It blocks like http.Server.Serve and always returns a non-nil error.
+// Note that request.TLS is nil for requests served over GM-TLS, since the connection
+// is not a *crypto/tls.Conn.
+func ServeGMTLS(server *http.Server, listener net.Listener, config *gmtls.Config) error {
+	if config == nil {
+		return errors.New("GM-TLS config is nil")
+	}
+	return server.Serve(gmtls.NewListener(listener, config))
+}
+
+// ListenAndServeGMTLS listens on server.Addr and serves GM-TLS connections using config.
+// If server.Addr is empty, ":https" is used.
+func ListenAndServeGMTLS(server *http.Server, config *gmtls.Config) error {
+	addr := server.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return ServeGMTLS(server, listener, config)
+}
@@ -0,0 +1,265 @@
+package httpx
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tjfoc/gmsm/gmtls"
+	gmsm_sm2 "github.com/tjfoc/gmsm/sm2"
+	gmsm_x509 "github.com/tjfoc/gmsm/x509"
+)
+
+// gmTestPKI holds an in-memory SM2 certificate authority and a dual server certificate issued by it.
+type gmTestPKI struct {
+	caPool  *gmsm_x509.CertPool
+	sigCert gmtls.Certificate
+	encCert gmtls.Certificate
+	sigPEM  [2][]byte // certificate and key PEM of the signing pair
+	encPEM  [2][]byte // certificate and key PEM of the encryption pair
+}
+
+// newGMTestPKI creates a self-signed SM2 CA and issues signing and encryption certificates for 127.0.0.1.
+func newGMTestPKI(t *testing.T) *gmTestPKI {
+	t.Helper()
+
+	caKey, err := gmsm_sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTemplate := &gmsm_x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "GM Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              gmsm_x509.KeyUsageCertSign | gmsm_x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SignatureAlgorithm:    gmsm_x509.SM2WithSM3,
+	}
+	caDER, err := gmsm_x509.CreateCertificate(caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	caCert, err := gmsm_x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	issue := func(serial int64, usage gmsm_x509.KeyUsage) (gmtls.Certificate, [2][]byte) {
+		key, err := gmsm_sm2.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		template := &gmsm_x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "127.0.0.1"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     usage,
+			ExtKeyUsage:  []gmsm_x509.ExtKeyUsage{gmsm_x509.ExtKeyUsageServerAuth},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			// SM2 signs the raw TBS data with SM3 internally, so the algorithm must be explicit.
+			SignatureAlgorithm: gmsm_x509.SM2WithSM3,
+		}
+		der, err := gmsm_x509.CreateCertificate(template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("failed to create certificate: %v", err)
+		}
+		keyPEM, err := gmsm_x509.WritePrivateKeyToPem(key, nil)
+		if err != nil {
+			t.Fatalf("failed to encode private key: %v", err)
+		}
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		return gmtls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, [2][]byte{certPEM, keyPEM}
+	}
+
+	pki := &gmTestPKI{caPool: gmsm_x509.NewCertPool()}
+	pki.caPool.AddCert(caCert)
+	pki.sigCert, pki.sigPEM = issue(2, gmsm_x509.KeyUsageDigitalSignature)
+	pki.encCert, pki.encPEM = issue(3, gmsm_x509.KeyUsageKeyEncipherment|gmsm_x509.KeyUsageDataEncipherment)
+	return pki
+}
+
+// startGMTLSServer starts a GM-TLS HTTP server on a loopback port and returns its base URL.
+func startGMTLSServer(t *testing.T, config *gmtls.Config) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "hello gm")
+		}),
+	}
+	go func() {
+		_ = ServeGMTLS(server, listener, config)
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	return "https://" + listener.Addr().String()
+}
+
+// TestGMTLS_Loopback runs a GM-TLS server and client in-process and performs a request.
+func TestGMTLS_Loopback(t *testing.T) {
+	pki := newGMTestPKI(t)
+	baseURL := startGMTLSServer(t, NewGMTLSServerConfig(pki.sigCert, pki.encCert))
+
+	client, err := NewHttpClient(
+		WithTimeout(10*time.Second),
+		WithTransport(WithTransportGMTLS(NewGMTLSClientConfig(pki.caPool))),
+	)
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+
+	resp, err := client.Get(baseURL + "/")
+	if err != nil {
+		t.Fatalf("GM-TLS request failed: %v", err)
+	}
+	body, err := ReadResponseAsBytes(resp)
+	if err != nil {
+		t.Fatalf("ReadResponseAsBytes failed: %v", err)
+	}
+	if string(body) != "hello gm" {
+		t.Errorf("Expected body %q, got %q", "hello gm", string(body))
+	}
+}
+
+// TestGMTLS_ProxyBypassed verifies that a proxy from the environment or an earlier option is not used,
+// as the transport would perform a standard TLS handshake through the CONNECT tunnel.
+func TestGMTLS_ProxyBypassed(t *testing.T) {
+	pki := newGMTestPKI(t)
+	baseURL := startGMTLSServer(t, NewGMTLSServerConfig(pki.sigCert, pki.encCert))
+
+	// The proxy counts the connections it receives and closes them.
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = proxy.Close()
+	})
+	var proxied atomic.Int32
+	go func() {
+		for {
+			conn, err := proxy.Accept()
+			if err != nil {
+				return
+			}
+			proxied.Add(1)
+			_ = conn.Close()
+		}
+	}()
+	proxyURL := "http://" + proxy.Addr().String()
+	t.Setenv("HTTPS_PROXY", proxyURL)
+	t.Setenv("https_proxy", proxyURL)
+
+	client, err := NewHttpClient(
+		WithTimeout(10*time.Second),
+		WithTransport(WithTransportProxyURL(proxyURL, "", ""), WithTransportGMTLS(NewGMTLSClientConfig(pki.caPool))),
+	)
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+	if client.Transport.(*http.Transport).Proxy != nil {
+		t.Error("Expected the GM-TLS transport to have no proxy")
+	}
+
+	resp, err := client.Get(baseURL + "/")
+	if err != nil {
+		t.Fatalf("GM-TLS request failed: %v", err)
+	}
+	CloseResponse(resp)
+	if n := proxied.Load(); n != 0 {
+		t.Errorf("Expected no proxy connections, got %d", n)
+	}
+}
+
+// TestGMTLS_UntrustedServer verifies that the client rejects a server certificate not issued by a trusted CA.
+func TestGMTLS_UntrustedServer(t *testing.T) {
+	serverPKI := newGMTestPKI(t)
+	otherPKI := newGMTestPKI(t)
+	baseURL := startGMTLSServer(t, NewGMTLSServerConfig(serverPKI.sigCert, serverPKI.encCert))
+
+	client, err := NewHttpClient(
+		WithTimeout(10*time.Second),
+		WithTransport(WithTransportGMTLS(NewGMTLSClientConfig(otherPKI.caPool))),
+	)
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+
+	if _, err = client.Get(baseURL + "/"); err == nil {
+		t.Error("Expected error for untrusted server certificate, got nil")
+	}
+}
+
+// TestLoadGMTLSServerConfig tests loading the dual certificate configuration from PEM files.
+func TestLoadGMTLSServerConfig(t *testing.T) {
+	pki := newGMTestPKI(t)
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"sig.crt": pki.sigPEM[0],
+		"sig.key": pki.sigPEM[1],
+		"enc.crt": pki.encPEM[0],
+		"enc.key": pki.encPEM[1],
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	config, err := LoadGMTLSServerConfig(
+		filepath.Join(dir, "sig.crt"), filepath.Join(dir, "sig.key"),
+		filepath.Join(dir, "enc.crt"), filepath.Join(dir, "enc.key"),
+	)
+	if err != nil {
+		t.Fatalf("LoadGMTLSServerConfig failed: %v", err)
+	}
+	if len(config.Certificates) != 2 {
+		t.Fatalf("Expected 2 certificates, got %d", len(config.Certificates))
+	}
+	if config.GMSupport == nil {
+		t.Error("Expected GMSupport to be set")
+	}
+
+	baseURL := startGMTLSServer(t, config)
+	client, err := NewHttpClient(
+		WithTimeout(10*time.Second),
+		WithTransport(WithTransportGMTLS(NewGMTLSClientConfig(pki.caPool))),
+	)
+	if err != nil {
+		t.Fatalf("NewHttpClient failed: %v", err)
+	}
+	resp, err := client.Get(baseURL + "/")
+	if err != nil {
+		t.Fatalf("GM-TLS request failed: %v", err)
+	}
+	CloseResponse(resp)
+
+	// Missing files must be reported as errors.
+	if _, err = LoadGMTLSServerConfig("missing.crt", "missing.key", "missing.crt", "missing.key"); err == nil {
+		t.Error("Expected error for missing files, got nil")
+	}
+}
+
+// TestWithTransportGMTLS_NilConfig tests that a nil configuration is rejected.
+func TestWithTransportGMTLS_NilConfig(t *testing.T) {
+	if _, err := NewHttpClient(WithTransport(WithTransportGMTLS(nil))); err == nil {
+		t.Error("Expected error for nil GM-TLS config, got nil")
+	}
+}
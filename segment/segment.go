@@ -0,0 +1,299 @@
+// Package segment provides a database-backed ID allocator in the style of Meituan's Leaf segment mode.
+// It hands out dense, increasing integer IDs per business tag by leasing ranges (segments) of IDs
+// from a database table, so the database is only hit once per segment.
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnavailable is returned when the segment of a tag is exhausted and no new segment could be loaded.
+	ErrUnavailable = errors.New("no ID segment available")
+
+	// DefaultTable is the default name of the table holding the segment allocations.
+	DefaultTable = "segment_allocs"
+
+	// DefaultStep is the default number of IDs in a segment of a newly created tag.
+	DefaultStep int64 = 1000
+)
+
+// Alloc is a row of the segment allocation table. The IDs up to MaxId have been handed out;
+// the next segment of a tag is (MaxId, MaxId + Step].
+type Alloc struct {
+	BizTag    string    `gorm:"primaryKey;size:128"` // 业务标识
+	MaxId     int64     `gorm:"not null"`            // 已分配的最大ID
+	Step      int64     `gorm:"not null"`            // 每个号段的长度
+	UpdatedAt time.Time // 最近一次分配号段的时间
+}
+
+// Option is a function type used to configure an Allocator.
+type Option func(a *Allocator)
+
+// WithTable returns an Option that sets the name of the allocation table.
+func WithTable(table string) Option {
+	return func(a *Allocator) {
+		a.table = table
+	}
+}
+
+// WithStep returns an Option that sets the segment length of tags created by the allocator.
+// The step of an existing tag is read from its row and can be changed there.
+func WithStep(step int64) Option {
+	return func(a *Allocator) {
+		a.step = step
+	}
+}
+
+// WithPrefetchThreshold returns an Option that sets when the next segment is loaded in the background:
+// once less than ratio of the current segment is left. The default is 0.9, as in Leaf,
+// which leaves most of a segment to cover a slow or unavailable database.
+func WithPrefetchThreshold(ratio float64) Option {
+	return func(a *Allocator) {
+		a.threshold = ratio
+	}
+}
+
+// WithRetry returns an Option that sets how often loading a segment is attempted and the backoff
+// before the first retry, which doubles with every further attempt.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(a *Allocator) {
+		a.attempts = attempts
+		a.backoff = backoff
+	}
+}
+
+// WithLoadTimeout returns an Option that sets the timeout of a single attempt to load a segment.
+func WithLoadTimeout(timeout time.Duration) Option {
+	return func(a *Allocator) {
+		a.timeout = timeout
+	}
+}
+
+// Allocator allocates IDs for several business tags from segments leased from a database table.
+//
+// Each tag has two buffers: while IDs are taken from the current segment, the next one is loaded
+// in the background, so callers rarely wait for the database. If the database is briefly unavailable,
+// IDs are still served from the segments already loaded, and loading is retried on later calls.
+//
+// The IDs of a tag are strictly increasing within one Allocator. Several allocators sharing the table
+// never hand out the same ID, but interleave their segments, so IDs are only roughly ordered across them.
+// IDs that were leased but not handed out before a restart are skipped.
+type Allocator struct {
+	db        *gorm.DB
+	table     string
+	step      int64
+	threshold float64
+	attempts  int
+	backoff   time.Duration
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	buffers map[string]*buffer // 每个业务标识的双缓冲
+	wg      sync.WaitGroup     // 正在进行的后台预加载
+}
+
+// buffer holds the current and the prefetched segment of a tag.
+type buffer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	current *segment // 当前号段
+	next    *segment // 预加载的下一个号段
+	loading bool     // 是否正在加载号段
+}
+
+// segment is a range of IDs leased from the database.
+type segment struct {
+	value int64 // 下一个可用ID
+	max   int64 // 号段内的最大ID (包含)
+	step  int64 // 号段长度
+}
+
+// NewAllocator creates a new Allocator backed by db and creates the allocation table if it doesn't exist.
+// Use a *gorm.DB created by the database package, e.g. database.NewDB.
+func NewAllocator(db *gorm.DB, opts ...Option) (*Allocator, error) {
+	a := &Allocator{
+		db:        db,
+		table:     DefaultTable,
+		step:      DefaultStep,
+		threshold: 0.9,
+		attempts:  3,
+		backoff:   100 * time.Millisecond,
+		timeout:   5 * time.Second,
+		buffers:   make(map[string]*buffer),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.step <= 0 {
+		return nil, fmt.Errorf("segment step must be positive, got %d", a.step)
+	}
+	if a.threshold < 0 || a.threshold > 1 {
+		return nil, fmt.Errorf("prefetch threshold must be between 0 and 1, got %g", a.threshold)
+	}
+	if a.attempts < 1 {
+		return nil, fmt.Errorf("load attempts must be at least 1, got %d", a.attempts)
+	}
+	if err := db.Table(a.table).AutoMigrate(&Alloc{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate segment table %s: %w", a.table, err)
+	}
+	return a, nil
+}
+
+// NextId returns the next ID of the business tag. The tag is created with the configured step
+// the first time it is used. It returns an error wrapping ErrUnavailable if the segment of the tag
+// is exhausted and no new segment could be loaded from the database.
+func (a *Allocator) NextId(tag string) (int64, error) {
+	if tag == "" {
+		return 0, errors.New("business tag can't be empty")
+	}
+	buf := a.buffer(tag)
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	for {
+		if cur := buf.current; cur != nil && cur.value <= cur.max {
+			id := cur.value
+			cur.value++
+			// Load the next segment in the background once the current one runs low.
+			if buf.next == nil && !buf.loading && float64(cur.max-cur.value+1) < a.threshold*float64(cur.step) {
+				buf.loading = true
+				a.wg.Add(1)
+				go a.prefetch(tag, buf)
+			}
+			return id, nil
+		}
+
+		// The current segment is exhausted: switch to the prefetched one or wait for it.
+		if buf.next != nil {
+			buf.current, buf.next = buf.next, nil
+			continue
+		}
+		if buf.loading {
+			buf.cond.Wait()
+			continue
+		}
+
+		// No segment is loaded yet, or the prefetch failed: load one now.
+		buf.loading = true
+		buf.mu.Unlock()
+		seg, err := a.load(tag)
+		buf.mu.Lock()
+		buf.loading = false
+		buf.cond.Broadcast()
+		if err != nil {
+			return 0, fmt.Errorf("%w for tag %s: %w", ErrUnavailable, tag, err)
+		}
+		buf.current = seg
+	}
+}
+
+// NextStringId returns the next ID of the business tag as a string.
+func (a *Allocator) NextStringId(tag string) (string, error) {
+	id, err := a.NextId(tag)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Close waits for background loads in progress to finish. It doesn't close the database.
+func (a *Allocator) Close() error {
+	a.wg.Wait()
+	return nil
+}
+
+// buffer returns the buffer of tag, creating it on first use.
+func (a *Allocator) buffer(tag string) *buffer {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	buf, ok := a.buffers[tag]
+	if !ok {
+		buf = &buffer{}
+		buf.cond = sync.NewCond(&buf.mu)
+		a.buffers[tag] = buf
+	}
+	return buf
+}
+
+// prefetch loads the next segment of tag in the background. If it fails, the current segment
+// is still used and the next call that runs out of IDs loads a segment itself.
+func (a *Allocator) prefetch(tag string, buf *buffer) {
+	defer a.wg.Done()
+
+	seg, err := a.load(tag)
+	if err != nil {
+		slog.Warn("Failed to prefetch ID segment", "tag", tag, "error", err)
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.loading = false
+	buf.next = seg
+	buf.cond.Broadcast()
+}
+
+// load leases the next segment of tag, retrying with exponential backoff.
+func (a *Allocator) load(tag string) (*segment, error) {
+	var err error
+	backoff := a.backoff
+	for attempt := 0; attempt < a.attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var seg *segment
+		if seg, err = a.fetch(tag); err == nil {
+			return seg, nil
+		}
+	}
+	return nil, err
+}
+
+// fetch advances the maximum ID of tag by its step in a transaction and returns the leased segment.
+func (a *Allocator) fetch(tag string) (*segment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	var row Alloc
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		advance := func() (int64, error) {
+			result := tx.Table(a.table).Where("biz_tag = ?", tag).
+				Updates(map[string]any{"max_id": gorm.Expr("max_id + step"), "updated_at": time.Now()})
+			return result.RowsAffected, result.Error
+		}
+		updated, err := advance()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			// First use of the tag: create it and lease its first segment.
+			err = tx.Table(a.table).Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&Alloc{BizTag: tag, MaxId: 0, Step: a.step, UpdatedAt: time.Now()}).Error
+			if err != nil {
+				return err
+			}
+			if _, err = advance(); err != nil {
+				return err
+			}
+		}
+		return tx.Table(a.table).Where("biz_tag = ?", tag).Take(&row).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease ID segment: %w", err)
+	}
+	if row.Step <= 0 {
+		return nil, fmt.Errorf("invalid step %d of tag %s", row.Step, tag)
+	}
+	return &segment{value: row.MaxId - row.Step + 1, max: row.MaxId, step: row.Step}, nil
+}
@@ -0,0 +1,160 @@
+package segment
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocosip/utils/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB opens an in-memory SQLite database through the database package.
+// Segment loads fail while the returned flag is set, simulating an unavailable database.
+func newTestDB(t *testing.T) (*gorm.DB, *atomic.Bool) {
+	dial, err := database.NewDialector(database.Sqlite, ":memory:")
+	assert.NoError(t, err)
+	db, err := database.NewDB(dial)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = database.CloseDB(db)
+	})
+
+	down := &atomic.Bool{}
+	err = db.Callback().Update().Before("gorm:update").Register("test:down", func(tx *gorm.DB) {
+		if down.Load() {
+			_ = tx.AddError(errors.New("database is down"))
+		}
+	})
+	assert.NoError(t, err)
+	return db, down
+}
+
+func TestAllocator_NextId(t *testing.T) {
+	db, _ := newTestDB(t)
+	a, err := NewAllocator(db, WithStep(10))
+	assert.NoError(t, err)
+	defer a.Close()
+
+	// IDs are dense and increasing across segment boundaries.
+	for want := int64(1); want <= 35; want++ {
+		id, err := a.NextId("order")
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+
+	// Tags are independent.
+	id, err := a.NextId("user")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	s, err := a.NextStringId("user")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", s)
+
+	_, err = a.NextId("")
+	assert.Error(t, err)
+
+	// The step of a tag is read from the table.
+	assert.NoError(t, a.Close())
+	var row Alloc
+	assert.NoError(t, db.Table(DefaultTable).Where("biz_tag = ?", "order").Take(&row).Error)
+	assert.Equal(t, int64(10), row.Step)
+	assert.GreaterOrEqual(t, row.MaxId, int64(40))
+}
+
+func TestAllocator_Concurrency(t *testing.T) {
+	db, _ := newTestDB(t)
+	first, err := NewAllocator(db, WithStep(50))
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := NewAllocator(db, WithStep(50))
+	assert.NoError(t, err)
+	defer second.Close()
+
+	var wg sync.WaitGroup
+	numGoroutines := 8
+	idsPerGoroutine := 500
+	generatedIDs := make(chan int64, numGoroutines*idsPerGoroutine)
+	for i := 0; i < numGoroutines; i++ {
+		a := first
+		if i%2 == 1 {
+			a = second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := int64(0)
+			for j := 0; j < idsPerGoroutine; j++ {
+				id, err := a.NextId("order")
+				assert.NoError(t, err)
+				assert.Greater(t, id, last)
+				last = id
+				generatedIDs <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(generatedIDs)
+
+	uniqueIDs := make(map[int64]struct{})
+	for id := range generatedIDs {
+		_, exists := uniqueIDs[id]
+		assert.False(t, exists, "Duplicate ID allocated: %d", id)
+		uniqueIDs[id] = struct{}{}
+	}
+	assert.Equal(t, numGoroutines*idsPerGoroutine, len(uniqueIDs))
+}
+
+func TestAllocator_DatabaseUnavailable(t *testing.T) {
+	db, down := newTestDB(t)
+	a, err := NewAllocator(db, WithStep(10), WithRetry(2, time.Millisecond))
+	assert.NoError(t, err)
+	defer a.Close()
+
+	// The first segment is loaded and the second one prefetched.
+	for want := int64(1); want <= 3; want++ {
+		id, err := a.NextId("order")
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+	assert.NoError(t, a.Close())
+
+	// While the database is down, the loaded segments are still used up.
+	down.Store(true)
+	for want := int64(4); want <= 20; want++ {
+		id, err := a.NextId("order")
+		assert.NoError(t, err)
+		assert.Equal(t, want, id)
+	}
+	_, err = a.NextId("order")
+	assert.True(t, errors.Is(err, ErrUnavailable))
+	_, err = a.NextId("other")
+	assert.True(t, errors.Is(err, ErrUnavailable))
+
+	// Once it is back, allocation continues with the next segment.
+	assert.NoError(t, a.Close())
+	down.Store(false)
+	id, err := a.NextId("order")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(21), id)
+}
+
+func TestNewAllocator_Invalid(t *testing.T) {
+	db, _ := newTestDB(t)
+	_, err := NewAllocator(db, WithStep(0))
+	assert.Error(t, err)
+	_, err = NewAllocator(db, WithPrefetchThreshold(1.5))
+	assert.Error(t, err)
+	_, err = NewAllocator(db, WithRetry(0, 0))
+	assert.Error(t, err)
+
+	a, err := NewAllocator(db, WithTable("custom_segments"), WithLoadTimeout(time.Second))
+	assert.NoError(t, err)
+	_, err = a.NextId("order")
+	assert.NoError(t, err)
+	assert.NoError(t, a.Close())
+	assert.True(t, db.Migrator().HasTable("custom_segments"))
+}
@@ -0,0 +1,145 @@
+package snowflake
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// AtomicDistributeId is a lock-free Snowflake ID generator.
+// Instead of a mutex it keeps the last timestamp and sequence in a single atomic word and advances it
+// with compare-and-swap, which scales better than DistributeId when many goroutines generate IDs concurrently.
+// It produces IDs with the same layout as DistributeId but only supports the RollbackFail policy,
+// extension bits and worker ID leases are not available.
+type AtomicDistributeId struct {
+	layout *DistributeId // 校验后的配置, 用于组合与解析 ID (不使用其锁与状态)
+	node   int64         // 预先组合好的数据中心ID与机器ID部分
+	state  atomic.Int64  // 上次生成ID的状态: (lastTimestamp - epoch) << sequenceBits | sequence
+
+	rollingBack     atomic.Bool  // 当前是否处于时钟回拨状态
+	rollbacks       atomic.Int64 // 检测到的时钟回拨事件次数
+	issued          atomic.Int64 // 已生成的ID数量
+	exhaustionWaits atomic.Int64 // 序列号耗尽后等待的次数
+}
+
+// NewAtomicWithDefault creates a new AtomicDistributeId instance with default epoch and bit lengths.
+func NewAtomicWithDefault(workerId int64, datacenterId int64) (*AtomicDistributeId, error) {
+	return NewAtomic(DefaultEpoch, DefaultWorkerIdBits, DefaultDatacenterIdBits, DefaultSequenceBits, workerId, datacenterId)
+}
+
+// NewAtomic creates a new AtomicDistributeId instance with custom configurations.
+// The parameters are validated the same way as by New.
+func NewAtomic(epoch int64, workerIdBits int, datacenterIdBits int, sequenceBits int, workerId int64, datacenterId int64) (*AtomicDistributeId, error) {
+	return newAtomic(New(epoch, workerIdBits, datacenterIdBits, sequenceBits, workerId, datacenterId))
+}
+
+// NewAtomicGenerator creates a new AtomicDistributeId instance configured by functional options.
+// Only the options describing the IDs are supported: WithLayout, WithEpoch, WithWorkerId, WithDatacenterId,
+// WithWorkerIDProvider and WithClock. Rollback policies, extension bits, leases and state stores are rejected.
+func NewAtomicGenerator(opts ...Option) (*AtomicDistributeId, error) {
+	return newAtomic(NewGenerator(append([]Option{lockFree()}, opts...)...))
+}
+
+// lockFree returns an Option that makes NewGenerator reject the options the lock-free generator doesn't support.
+func lockFree() Option {
+	return func(d *DistributeId) error {
+		d.lockFree = true
+		return nil
+	}
+}
+
+// checkLockFree returns an error if an option not supported by the lock-free generator is set.
+// Providers implementing LeaseChecker are rejected before they are asked for a worker ID,
+// so no lease is acquired and left behind.
+func (d *DistributeId) checkLockFree() error {
+	_, leased := d.provider.(LeaseChecker)
+	if d.rollbackPolicy != RollbackFail || d.extensionBits > 0 || d.lease != nil || leased || d.stateStore != nil {
+		return errors.New("the lock-free generator supports no rollback policy, extension bits, lease or state store")
+	}
+	return nil
+}
+
+// NewAtomicWithLayout creates a new AtomicDistributeId instance that generates IDs with the given layout.
+func NewAtomicWithLayout(layout Layout, workerId int64, datacenterId int64) (*AtomicDistributeId, error) {
+	return newAtomic(NewWithLayout(layout, workerId, datacenterId))
+}
+
+// newAtomic creates an AtomicDistributeId using the validated configuration of layout.
+func newAtomic(layout *DistributeId, err error) (*AtomicDistributeId, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicDistributeId{
+		layout: layout,
+		node:   (layout.datacenterId << layout.datacenterIdShift) | (layout.workerId << layout.workerIdShift),
+	}, nil
+}
+
+// NextId generates a unique 64-bit Snowflake ID without taking a lock.
+// It returns an error if the clock moved backwards or past the last time of the layout. When the sequence of the current millisecond
+// is exhausted, it yields the processor until the clock moves on.
+func (a *AtomicDistributeId) NextId() (int64, error) {
+	l := a.layout
+	waited := false
+	for {
+		last := a.state.Load()
+		lastTimestamp := last >> l.sequenceBits
+		timestamp := l.timeGen() - l.epoch
+
+		var next int64
+		switch {
+		case timestamp > lastTimestamp:
+			// A new millisecond starts with sequence 0.
+			next = timestamp << l.sequenceBits
+		case timestamp < lastTimestamp:
+			if a.rollingBack.CompareAndSwap(false, true) {
+				a.rollbacks.Add(1)
+			}
+			return 0, l.rollbackError(lastTimestamp - timestamp)
+		case last&l.sequenceMask == l.sequenceMask:
+			// The sequence of this millisecond is exhausted; let other goroutines run until the clock moves on.
+			if !waited {
+				waited = true
+				a.exhaustionWaits.Add(1)
+			}
+			runtime.Gosched()
+			continue
+		default:
+			next = last + 1
+		}
+		// The timestamp must fit into its bits, otherwise it would overflow into the sign bit.
+		if err := l.checkTimestamp(next >> l.sequenceBits); err != nil {
+			return 0, err
+		}
+
+		if a.state.CompareAndSwap(last, next) {
+			a.rollingBack.Store(false)
+			a.issued.Add(1)
+			return (next>>l.sequenceBits)<<l.timestampLeftShift | a.node | (next&l.sequenceMask)<<l.sequenceShift, nil
+		}
+	}
+}
+
+// NextStringId generates a unique Snowflake ID and returns it as a string.
+func (a *AtomicDistributeId) NextStringId() (string, error) {
+	id, err := a.NextId()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Stats returns the counters of the generator.
+func (a *AtomicDistributeId) Stats() Stats {
+	return Stats{
+		Issued:          a.issued.Load(),
+		ExhaustionWaits: a.exhaustionWaits.Load(),
+		Rollbacks:       a.rollbacks.Load(),
+	}
+}
+
+// Parse decomposes a Snowflake ID generated by this instance back into its components.
+func (a *AtomicDistributeId) Parse(id int64) IdParts {
+	return a.layout.Parse(id)
+}
@@ -0,0 +1,120 @@
+package snowflake
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAtomic(t *testing.T) {
+	id, err := NewAtomicWithDefault(1, 2)
+	assert.NoError(t, err)
+	assert.NotNil(t, id)
+
+	_, err = NewAtomic(DefaultEpoch, 5, 5, 12, 32, 0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "worker ID can't be greater than")
+}
+
+func TestAtomicDistributeId_NextId_Concurrency(t *testing.T) {
+	id, err := NewAtomicWithDefault(3, 4)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	numGoroutines := 16
+	idsPerGoroutine := 2000
+	generatedIDs := make(chan int64, numGoroutines*idsPerGoroutine)
+
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < idsPerGoroutine; j++ {
+				newID, err := id.NextId()
+				assert.NoError(t, err)
+				generatedIDs <- newID
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(generatedIDs)
+
+	uniqueIDs := make(map[int64]struct{})
+	for newID := range generatedIDs {
+		_, exists := uniqueIDs[newID]
+		assert.False(t, exists, "Duplicate ID generated in concurrent test: %d", newID)
+		uniqueIDs[newID] = struct{}{}
+
+		parts := id.Parse(newID)
+		assert.Equal(t, int64(3), parts.WorkerId)
+		assert.Equal(t, int64(4), parts.DatacenterId)
+	}
+	assert.Equal(t, numGoroutines*idsPerGoroutine, len(uniqueIDs), "Not all IDs were unique")
+}
+
+func TestAtomicDistributeId_SameLayoutAsDistributeId(t *testing.T) {
+	now := mockClock(1000)
+	atomicID, err := NewAtomicGenerator(WithWorkerId(7), WithDatacenterId(9), WithClock(now))
+	assert.NoError(t, err)
+	mutexID, err := NewWithDefault(7, 9, WithClock(now))
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		a, err := atomicID.NextId()
+		assert.NoError(t, err)
+		m, err := mutexID.NextId()
+		assert.NoError(t, err)
+		assert.Equal(t, m, a)
+	}
+	now.Add(1)
+	a, err := atomicID.NextId()
+	assert.NoError(t, err)
+	m, err := mutexID.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, m, a)
+
+	strID, err := atomicID.NextStringId()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, strID)
+}
+
+func TestAtomicDistributeId_ClockRollback(t *testing.T) {
+	now := mockClock(1000)
+	id, err := NewAtomicGenerator(WithClock(now))
+	assert.NoError(t, err)
+
+	_, err = id.NextId()
+	assert.NoError(t, err)
+
+	now.Store(999)
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+}
+
+func TestAtomicDistributeId_SequenceOverflow(t *testing.T) {
+	now := mockClock(1000)
+	layout := DefaultLayout
+	layout.SequenceBits = 2
+	id, err := NewAtomicGenerator(WithLayout(layout), WithClock(now))
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err = id.NextId()
+		assert.NoError(t, err)
+	}
+
+	// The fifth ID must wait for the next millisecond.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		now.Store(1001)
+	}()
+	newID, err := id.NextId()
+	assert.NoError(t, err)
+	parts := id.Parse(newID)
+	assert.Equal(t, int64(mockBase+1001), parts.Time.UnixMilli())
+	assert.Equal(t, int64(0), parts.Sequence)
+}
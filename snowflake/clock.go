@@ -0,0 +1,20 @@
+package snowflake
+
+import "time"
+
+// Clock is the source of the current time of a generator.
+// Each generator has its own clock, so tests can give generators fake clocks with WithClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts an ordinary function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock that reads the system time. It is used by default.
+var SystemClock Clock = ClockFunc(time.Now)
@@ -0,0 +1,184 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// ErrInvalidChecksum is returned when decoding a checksum-validated string whose check symbol doesn't match.
+var ErrInvalidChecksum = errors.New("invalid checksum")
+
+var (
+	// Base32 is the Crockford base32 encoding. Decoding is case-insensitive, accepts O as 0 and
+	// I and L as 1, and ignores hyphens, which makes it suitable for codes entered by humans.
+	// Encoded IDs are 13 characters long; the checked variant appends Crockford's mod 37 check symbol.
+	Base32 = newEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ", crockfordChecksum{}, true)
+
+	// Base58 is the base58 encoding with the Bitcoin alphabet, which avoids the look-alike characters 0, O, I and l.
+	// Encoded IDs are 11 characters long; the checked variant appends a Luhn mod 58 check character.
+	Base58 = newEncoding("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz", luhnChecksum{}, false)
+
+	// Base62 is the base62 encoding with digits, upper-case and lower-case letters, which is URL safe.
+	// Encoded IDs are 11 characters long; the checked variant appends a Luhn mod 62 check character.
+	Base62 = newEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", luhnChecksum{}, false)
+)
+
+// Encoding is a compact, order-preserving text encoding of Snowflake IDs.
+// Encoded IDs are zero-padded to a fixed width and the alphabets are in ASCII order,
+// so the encoded strings sort in the same order as the numeric IDs. Snowflake IDs are never negative:
+// Encode rejects negative IDs and Decode rejects values above math.MaxInt64.
+type Encoding struct {
+	alphabet  string
+	decodeMap [256]int8 // 字符到数值的映射, -1 表示非法字符
+	width     int       // 编码后的固定长度, 足以表示 64 位的值
+	checksum  checksum
+	crockford bool // 是否启用 Crockford 的宽松解码规则 (大小写不敏感, 别名字符, 忽略连字符)
+}
+
+// checksum computes and verifies the check symbol of an encoded string.
+type checksum interface {
+	// symbol returns the check symbol for value and its encoding s.
+	symbol(e *Encoding, value uint64, s string) byte
+}
+
+// newEncoding creates an Encoding for alphabet, which must be in ascending ASCII order.
+// crockford enables the lenient decoding rules of Crockford base32.
+func newEncoding(alphabet string, sum checksum, crockford bool) *Encoding {
+	e := &Encoding{
+		alphabet:  alphabet,
+		checksum:  sum,
+		crockford: crockford,
+	}
+	for i := range e.decodeMap {
+		e.decodeMap[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		e.decodeMap[alphabet[i]] = int8(i)
+	}
+	if e.crockford {
+		for i := 0; i < len(alphabet); i++ {
+			e.decodeMap[strings.ToLower(alphabet[i : i+1])[0]] = int8(i)
+		}
+		e.decodeMap['O'], e.decodeMap['o'] = 0, 0
+		e.decodeMap['I'], e.decodeMap['i'], e.decodeMap['L'], e.decodeMap['l'] = 1, 1, 1, 1
+	}
+	// The fixed width is the number of digits needed for the largest 64-bit value.
+	for v := uint64(1<<64 - 1); v > 0; v /= uint64(len(alphabet)) {
+		e.width++
+	}
+	return e
+}
+
+// Encode returns the fixed-width encoding of id. It returns an error if id is negative,
+// as a negative ID would sort after all positive ones.
+func (e *Encoding) Encode(id int64) (string, error) {
+	if id < 0 {
+		return "", fmt.Errorf("can't encode negative ID %d", id)
+	}
+	buf := make([]byte, e.width)
+	base := uint64(len(e.alphabet))
+	v := uint64(id)
+	for i := e.width - 1; i >= 0; i-- {
+		buf[i] = e.alphabet[v%base]
+		v /= base
+	}
+	return string(buf), nil
+}
+
+// Decode parses an encoded ID. Leading zero characters may be omitted.
+func (e *Encoding) Decode(s string) (int64, error) {
+	v, err := e.decode(e.normalize(s))
+	return int64(v), err
+}
+
+// EncodeCheck returns the fixed-width encoding of id followed by a check symbol,
+// which detects single mistyped characters and most transpositions of adjacent characters.
+// Like Encode, it returns an error if id is negative.
+func (e *Encoding) EncodeCheck(id int64) (string, error) {
+	s, err := e.Encode(id)
+	if err != nil {
+		return "", err
+	}
+	return s + string(e.checksum.symbol(e, uint64(id), s)), nil
+}
+
+// DecodeCheck parses an ID encoded by EncodeCheck and verifies its check symbol.
+// It returns ErrInvalidChecksum if the check symbol doesn't match.
+func (e *Encoding) DecodeCheck(s string) (int64, error) {
+	s = e.normalize(s)
+	if len(s) < 2 {
+		return 0, fmt.Errorf("encoded ID %q is too short", s)
+	}
+	body, check := s[:len(s)-1], s[len(s)-1]
+	v, err := e.decode(body)
+	if err != nil {
+		return 0, err
+	}
+	if e.crockford && check >= 'a' && check <= 'z' {
+		check -= 'a' - 'A'
+	}
+	if e.checksum.symbol(e, v, body) != check {
+		return 0, fmt.Errorf("%w in encoded ID %q", ErrInvalidChecksum, s)
+	}
+	return int64(v), nil
+}
+
+// normalize removes the hyphens that Crockford base32 allows for readability.
+func (e *Encoding) normalize(s string) string {
+	if e.crockford {
+		return strings.ReplaceAll(s, "-", "")
+	}
+	return s
+}
+
+// decode converts the digits of s into a value, checking for invalid characters and values
+// that don't fit into a non-negative int64.
+func (e *Encoding) decode(s string) (uint64, error) {
+	if s == "" || len(s) > e.width {
+		return 0, fmt.Errorf("encoded ID %q must have 1 to %d characters", s, e.width)
+	}
+	base := uint64(len(e.alphabet))
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		digit := e.decodeMap[s[i]]
+		if digit < 0 {
+			return 0, fmt.Errorf("invalid character %q in encoded ID %q", s[i], s)
+		}
+		hi, lo := bits.Mul64(v, base)
+		lo, carry := bits.Add64(lo, uint64(digit), 0)
+		if hi != 0 || carry != 0 {
+			return 0, fmt.Errorf("encoded ID %q overflows 64 bits", s)
+		}
+		v = lo
+	}
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("encoded ID %q overflows the 63 bits of a non-negative int64", s)
+	}
+	return v, nil
+}
+
+// crockfordChecksum is the check symbol defined by Crockford base32: the value modulo 37,
+// using the 32 encoding symbols followed by *, ~, $, = and U.
+type crockfordChecksum struct{}
+
+func (crockfordChecksum) symbol(_ *Encoding, value uint64, _ string) byte {
+	const symbols = "0123456789ABCDEFGHJKMNPQRSTVWXYZ*~$=U"
+	return symbols[value%37]
+}
+
+// luhnChecksum is the Luhn mod N algorithm applied to the encoded characters.
+type luhnChecksum struct{}
+
+func (luhnChecksum) symbol(e *Encoding, _ uint64, s string) byte {
+	n := len(e.alphabet)
+	factor, sum := 2, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		addend := factor * int(e.decodeMap[s[i]])
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return e.alphabet[(n-sum%n)%n]
+}
@@ -0,0 +1,192 @@
+package snowflake
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mustEncode encodes v with enc, failing the test on error.
+func mustEncode(t *testing.T, enc *Encoding, v int64) string {
+	s, err := enc.Encode(v)
+	assert.NoError(t, err)
+	return s
+}
+
+// mustEncodeCheck encodes v with enc and a check symbol, failing the test on error.
+func mustEncodeCheck(t *testing.T, enc *Encoding, v int64) string {
+	s, err := enc.EncodeCheck(v)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestEncoding_Width(t *testing.T) {
+	assert.Len(t, mustEncode(t, Base32, 0), 13)
+	assert.Len(t, mustEncode(t, Base58, 0), 11)
+	assert.Len(t, mustEncode(t, Base62, 0), 11)
+	assert.Len(t, mustEncode(t, Base62, math.MaxInt64), 11)
+	assert.Len(t, mustEncodeCheck(t, Base58, math.MaxInt64), 12)
+}
+
+func TestEncoding_RoundTrip(t *testing.T) {
+	values := []int64{0, 1, 31, 32, 57, 58, 61, 62, 4096, 1234567890123456789, math.MaxInt64}
+	for _, enc := range []*Encoding{Base32, Base58, Base62} {
+		for _, v := range values {
+			decoded, err := enc.Decode(mustEncode(t, enc, v))
+			assert.NoError(t, err)
+			assert.Equal(t, v, decoded)
+
+			decoded, err = enc.DecodeCheck(mustEncodeCheck(t, enc, v))
+			assert.NoError(t, err)
+			assert.Equal(t, v, decoded)
+		}
+	}
+}
+
+func TestEncoding_KnownValues(t *testing.T) {
+	assert.Equal(t, "000000000000Z", mustEncode(t, Base32, 31))
+	assert.Equal(t, "0000000000010", mustEncode(t, Base32, 32))
+	assert.Equal(t, "11111111112", mustEncode(t, Base58, 1))
+	assert.Equal(t, "0000000000z", mustEncode(t, Base62, 61))
+	assert.Equal(t, "7ZZZZZZZZZZZZ", mustEncode(t, Base32, math.MaxInt64))
+
+	// Crockford check symbol is the value modulo 37.
+	assert.Equal(t, "0000000000010*", mustEncodeCheck(t, Base32, 32))
+	assert.Equal(t, "0000000000014U", mustEncodeCheck(t, Base32, 36))
+}
+
+func TestEncoding_PreservesOrder(t *testing.T) {
+	id, err := NewWithDefault(1, 1)
+	assert.NoError(t, err)
+
+	ids := make([]int64, 0, 200)
+	for i := 0; i < 100; i++ {
+		newID, err := id.NextId()
+		assert.NoError(t, err)
+		ids = append(ids, newID)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		ids = append(ids, r.Int63())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, enc := range []*Encoding{Base32, Base58, Base62} {
+		encoded := make([]string, len(ids))
+		checked := make([]string, len(ids))
+		for i, v := range ids {
+			encoded[i] = mustEncode(t, enc, v)
+			checked[i] = mustEncodeCheck(t, enc, v)
+		}
+		assert.True(t, sort.StringsAreSorted(encoded), "Encoded IDs must sort like the numeric IDs")
+		assert.True(t, sort.StringsAreSorted(checked), "Checked IDs must sort like the numeric IDs")
+	}
+}
+
+func TestEncoding_Base32Lenient(t *testing.T) {
+	v := int64(1234567890123456789)
+	encoded := mustEncode(t, Base32, v)
+
+	// Lower case, hyphens and look-alike characters are accepted.
+	decoded, err := Base32.Decode(encoded[:4] + "-" + encoded[4:8] + "-" + strings.ToLower(encoded[8:]))
+	assert.NoError(t, err)
+	assert.Equal(t, v, decoded)
+
+	lower := []byte(mustEncodeCheck(t, Base32, v))
+	for i := range lower {
+		if lower[i] >= 'A' && lower[i] <= 'Z' {
+			lower[i] += 'a' - 'A'
+		}
+	}
+	decoded, err = Base32.DecodeCheck(string(lower))
+	assert.NoError(t, err)
+	assert.Equal(t, v, decoded)
+
+	decoded, err = Base32.Decode("O0Il1L")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0<<25|0<<20|1<<15|1<<10|1<<5|1), decoded)
+
+	// Leading zeros may be omitted.
+	decoded, err = Base32.Decode("Z")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(31), decoded)
+}
+
+func TestEncoding_Invalid(t *testing.T) {
+	_, err := Base32.Decode("")
+	assert.Error(t, err)
+	_, err = Base32.Decode("U")
+	assert.Error(t, err, "U is not a Crockford data symbol")
+	_, err = Base58.Decode("0OIl")
+	assert.Error(t, err)
+	_, err = Base62.Decode("abc-def")
+	assert.Error(t, err)
+	_, err = Base62.Decode("000000000000")
+	assert.Error(t, err, "Too long")
+	_, err = Base62.Decode("zzzzzzzzzzz")
+	assert.Error(t, err, "Overflows 64 bits")
+	_, err = Base32.DecodeCheck("1")
+	assert.Error(t, err)
+
+	// Values above math.MaxInt64 would come back as negative IDs.
+	for _, s := range []string{"FZZZZZZZZZZZZ", "8000000000000"} {
+		_, err = Base32.Decode(s)
+		assert.Error(t, err, s)
+		_, err = Base32.DecodeCheck(s + "0")
+		assert.Error(t, err, s)
+	}
+	_, err = Base62.Decode("LygHa16AHYF")
+	assert.Error(t, err)
+}
+
+func TestEncoding_NegativeID(t *testing.T) {
+	for _, enc := range []*Encoding{Base32, Base58, Base62} {
+		_, err := enc.Encode(-1)
+		assert.Error(t, err)
+		_, err = enc.EncodeCheck(math.MinInt64)
+		assert.Error(t, err)
+	}
+
+	// A negative ID accepted from a client is rejected rather than panicking.
+	var id ID
+	assert.NoError(t, id.UnmarshalJSON([]byte(`"-5"`)))
+	_, err := Base62.Encode(int64(id))
+	assert.Error(t, err)
+}
+
+func TestEncoding_DetectsTypos(t *testing.T) {
+	for _, enc := range []*Encoding{Base32, Base58, Base62} {
+		checked := mustEncodeCheck(t, enc, 1234567890123456789)
+
+		// Replace every character with every other character of the alphabet.
+		for i := 0; i < len(checked)-1; i++ {
+			for j := 0; j < len(enc.alphabet); j++ {
+				if enc.alphabet[j] == checked[i] {
+					continue
+				}
+				typo := checked[:i] + string(enc.alphabet[j]) + checked[i+1:]
+				if _, err := enc.DecodeCheck(typo); err == nil {
+					t.Errorf("Typo %q of %q was not detected", typo, checked)
+				}
+			}
+		}
+
+		// Swap adjacent characters.
+		for i := 0; i < len(checked)-2; i++ {
+			if checked[i] == checked[i+1] {
+				continue
+			}
+			swapped := checked[:i] + string(checked[i+1]) + string(checked[i]) + checked[i+2:]
+			_, err := enc.DecodeCheck(swapped)
+			assert.True(t, err != nil, "Transposition %q of %q was not detected", swapped, checked)
+		}
+	}
+
+	_, err := Base62.DecodeCheck(mustEncode(t, Base62, 42) + "0")
+	assert.True(t, errors.Is(err, ErrInvalidChecksum))
+}
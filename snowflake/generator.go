@@ -0,0 +1,32 @@
+package snowflake
+
+// IDGenerator is the interface of the 64-bit ID generators of this package: DistributeId and AtomicDistributeId.
+// Code that needs IDs, such as GormPlugin, can depend on it and switch between the implementations.
+type IDGenerator interface {
+	StringIDGenerator
+	// NextId generates a unique 64-bit ID.
+	NextId() (int64, error)
+}
+
+// StringIDGenerator is the interface shared by the ID generators of this package:
+// DistributeId, AtomicDistributeId, UUIDv7Generator and ULIDGenerator.
+// Code that stores IDs as strings can depend on it and switch between ID strategies.
+type StringIDGenerator interface {
+	// NextStringId generates a unique ID and returns it in its canonical string form.
+	NextStringId() (string, error)
+}
+
+// Stats holds counters of a generator since it was created.
+type Stats struct {
+	Issued          int64 // 已生成的ID数量
+	ExhaustionWaits int64 // 序列号耗尽后等待下一个时间单位的次数
+	Rollbacks       int64 // 检测到的时钟回拨事件次数
+}
+
+// Compile-time checks that the generators implement the interfaces.
+var (
+	_ IDGenerator       = (*DistributeId)(nil)
+	_ IDGenerator       = (*AtomicDistributeId)(nil)
+	_ StringIDGenerator = (*UUIDv7Generator)(nil)
+	_ StringIDGenerator = (*ULIDGenerator)(nil)
+)
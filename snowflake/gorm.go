@@ -0,0 +1,73 @@
+package snowflake
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// idType is the reflect type of ID, used to find the fields filled by GormPlugin.
+var idType = reflect.TypeOf(ID(0))
+
+// GormPlugin is a GORM plugin that fills zero ID primary keys with a new Snowflake ID on create.
+// Register it with db.Use(snowflake.NewGormPlugin(generator)). Models should disable auto increment
+// on the primary key, e.g. `gorm:"primaryKey;autoIncrement:false"`.
+type GormPlugin struct {
+	generator IDGenerator
+}
+
+// NewGormPlugin creates a new GormPlugin that takes IDs from generator, e.g. a *DistributeId or *AtomicDistributeId.
+// If generator is nil, the Default instance is used.
+func NewGormPlugin(generator IDGenerator) *GormPlugin {
+	if generator == nil {
+		generator = Default
+	}
+	return &GormPlugin{generator: generator}
+}
+
+// Name implements gorm.Plugin.
+func (p *GormPlugin) Name() string {
+	return "snowflake"
+}
+
+// Initialize implements gorm.Plugin. It registers a callback that runs before "gorm:create".
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("snowflake:assign_id", p.assignIds)
+}
+
+// assignIds fills the zero ID primary keys of the created record or records.
+func (p *GormPlugin) assignIds(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		if field.FieldType != idType {
+			continue
+		}
+		switch rv := reflect.Indirect(db.Statement.ReflectValue); rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < rv.Len(); i++ {
+				p.assignId(db, field, reflect.Indirect(rv.Index(i)))
+			}
+		case reflect.Struct:
+			p.assignId(db, field, rv)
+		}
+	}
+}
+
+// assignId sets field of the record rv to a new ID if it is zero.
+func (p *GormPlugin) assignId(db *gorm.DB, field *schema.Field, rv reflect.Value) {
+	if _, isZero := field.ValueOf(db.Statement.Context, rv); !isZero {
+		return
+	}
+	id, err := p.generator.NextId()
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("failed to generate snowflake ID for %s: %w", field.Name, err))
+		return
+	}
+	if err = field.Set(db.Statement.Context, rv, ID(id)); err != nil {
+		_ = db.AddError(fmt.Errorf("failed to set snowflake ID for %s: %w", field.Name, err))
+	}
+}
@@ -0,0 +1,83 @@
+package snowflake
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gormOrder is a model with a snowflake ID primary key.
+type gormOrder struct {
+	Id     ID `gorm:"primaryKey;autoIncrement:false"`
+	Name   string
+	UserId ID
+}
+
+// failingGenerator always fails to generate an ID.
+type failingGenerator struct{}
+
+func (failingGenerator) NextId() (int64, error) {
+	return 0, errors.New("generator failed")
+}
+
+func (failingGenerator) NextStringId() (string, error) {
+	return "", errors.New("generator failed")
+}
+
+func TestGormPlugin(t *testing.T) {
+	db := newTestDB(t)
+	generator, err := NewWithDefault(2, 3)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(NewGormPlugin(generator)))
+	assert.NoError(t, db.AutoMigrate(&gormOrder{}))
+
+	// A single record gets a new ID; non-primary ID fields are left alone.
+	order := gormOrder{Name: "single"}
+	assert.NoError(t, db.Create(&order).Error)
+	assert.NotZero(t, order.Id)
+	assert.Zero(t, order.UserId)
+	assert.Equal(t, int64(2), generator.Parse(order.Id.Int64()).WorkerId)
+
+	// An explicitly set ID is kept.
+	explicit := gormOrder{Id: 12345, Name: "explicit"}
+	assert.NoError(t, db.Create(&explicit).Error)
+	assert.Equal(t, ID(12345), explicit.Id)
+
+	// Batches get distinct IDs, for slices of values and of pointers.
+	batch := []gormOrder{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	assert.NoError(t, db.Create(&batch).Error)
+	pointers := []*gormOrder{{Name: "d"}, {Name: "e"}}
+	assert.NoError(t, db.Create(&pointers).Error)
+	seen := map[ID]struct{}{order.Id: {}}
+	for _, o := range append(pointers, &batch[0], &batch[1], &batch[2]) {
+		assert.NotZero(t, o.Id)
+		_, exists := seen[o.Id]
+		assert.False(t, exists, "Duplicate ID assigned: %d", o.Id)
+		seen[o.Id] = struct{}{}
+	}
+
+	// The IDs round-trip through the database.
+	var loaded gormOrder
+	assert.NoError(t, db.First(&loaded, "id = ?", order.Id).Error)
+	assert.Equal(t, order, loaded)
+	var count int64
+	assert.NoError(t, db.Model(&gormOrder{}).Count(&count).Error)
+	assert.Equal(t, int64(7), count)
+}
+
+func TestGormPlugin_GeneratorError(t *testing.T) {
+	db := newTestDB(t)
+	assert.NoError(t, db.Use(NewGormPlugin(failingGenerator{})))
+	assert.NoError(t, db.AutoMigrate(&gormOrder{}))
+
+	err := db.Create(&gormOrder{Name: "fails"}).Error
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "generator failed")
+}
+
+func TestNewGormPlugin_Default(t *testing.T) {
+	plugin := NewGormPlugin(nil)
+	assert.Equal(t, "snowflake", plugin.Name())
+	assert.Equal(t, Default, plugin.generator)
+}
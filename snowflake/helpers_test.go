@@ -0,0 +1,21 @@
+package snowflake
+
+import (
+	"testing"
+
+	"github.com/cocosip/utils/database"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestDB opens an in-memory SQLite database through the database package.
+func newTestDB(t *testing.T) *gorm.DB {
+	dial, err := database.NewDialector(database.Sqlite, ":memory:")
+	assert.NoError(t, err)
+	db, err := database.NewDB(dial)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = database.CloseDB(db)
+	})
+	return db
+}
@@ -0,0 +1,115 @@
+package snowflake
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ID is a Snowflake ID that is safe to pass to JavaScript clients.
+// It is stored as a 64-bit integer in databases but marshals to JSON as a string,
+// because JavaScript numbers lose precision above 2^53. When unmarshaling it accepts
+// both a JSON string and a JSON number.
+type ID int64
+
+// Int64 returns the ID as an int64.
+func (id ID) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the ID as a decimal string.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// ParseID parses a decimal string into an ID.
+func ParseID(s string) (ID, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid snowflake ID %q: %w", s, err)
+	}
+	return ID(v), nil
+}
+
+// MarshalJSON implements json.Marshaler. The ID is encoded as a JSON string.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(id.String())), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts a JSON string or a JSON number.
+// A JSON null leaves the ID unchanged.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("invalid snowflake ID %s: %w", data, err)
+		}
+		data = []byte(s)
+	}
+	v, err := ParseID(string(data))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *ID) UnmarshalText(text []byte) error {
+	v, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = v
+	return nil
+}
+
+// Scan implements sql.Scanner. It accepts integer, string and []byte column values.
+// A NULL column value sets the ID to 0.
+func (id *ID) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*id = 0
+	case int64:
+		*id = ID(v)
+	case []byte:
+		return id.UnmarshalText(v)
+	case string:
+		return id.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("can't scan %T into snowflake ID", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. The ID is stored as a 64-bit integer.
+func (id ID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface.
+func (ID) GormDataType() string {
+	return "bigint"
+}
+
+// GormDBDataType implements migrator.GormDataTypeInterface and returns the column type for each dialect.
+func (ID) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "integer"
+	}
+	return "bigint"
+}
@@ -0,0 +1,93 @@
+package snowflake
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Compile-time checks that ID implements the expected interfaces.
+var (
+	_ json.Marshaler           = ID(0)
+	_ json.Unmarshaler         = (*ID)(nil)
+	_ encoding.TextMarshaler   = ID(0)
+	_ encoding.TextUnmarshaler = (*ID)(nil)
+	_ sql.Scanner              = (*ID)(nil)
+	_ driver.Valuer            = ID(0)
+)
+
+func TestID_JSON(t *testing.T) {
+	type payload struct {
+		Id       ID  `json:"id"`
+		ParentId *ID `json:"parentId,omitempty"`
+	}
+
+	data, err := json.Marshal(payload{Id: 1234567890123456789})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"1234567890123456789"}`, string(data))
+
+	// Both strings and numbers are accepted.
+	var p payload
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"1234567890123456789","parentId":42}`), &p))
+	assert.Equal(t, ID(1234567890123456789), p.Id)
+	assert.Equal(t, ID(42), *p.ParentId)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":1234567890123456789}`), &p))
+	assert.Equal(t, ID(1234567890123456789), p.Id)
+
+	// null leaves the value unchanged.
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":null}`), &p))
+	assert.Equal(t, ID(1234567890123456789), p.Id)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"abc"}`), &p))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":1.5}`), &p))
+	assert.Error(t, json.Unmarshal([]byte(`{"id":true}`), &p))
+}
+
+func TestID_Text(t *testing.T) {
+	text, err := ID(987654321).MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "987654321", string(text))
+
+	var id ID
+	assert.NoError(t, id.UnmarshalText([]byte("123")))
+	assert.Equal(t, ID(123), id)
+	assert.Error(t, id.UnmarshalText([]byte("12a")))
+
+	// Map keys use the text encoding.
+	data, err := json.Marshal(map[ID]string{7: "seven"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"7":"seven"}`, string(data))
+}
+
+func TestID_SQL(t *testing.T) {
+	value, err := ID(55).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(55), value)
+
+	var id ID
+	assert.NoError(t, id.Scan(int64(66)))
+	assert.Equal(t, ID(66), id)
+	assert.NoError(t, id.Scan([]byte("77")))
+	assert.Equal(t, ID(77), id)
+	assert.NoError(t, id.Scan("88"))
+	assert.Equal(t, ID(88), id)
+	assert.NoError(t, id.Scan(nil))
+	assert.Equal(t, ID(0), id)
+	assert.Error(t, id.Scan(1.5))
+}
+
+func TestID_String(t *testing.T) {
+	assert.Equal(t, "-1", ID(-1).String())
+	assert.Equal(t, int64(99), ID(99).Int64())
+
+	id, err := ParseID("4096")
+	assert.NoError(t, err)
+	assert.Equal(t, ID(4096), id)
+	_, err = ParseID("")
+	assert.Error(t, err)
+}
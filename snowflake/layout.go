@@ -0,0 +1,106 @@
+package snowflake
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout describes how the components of a Snowflake ID are packed into 63 bits
+// and the resolution of the timestamp.
+type Layout struct {
+	Epoch            int64         // 开始时间截 (Unix 毫秒), ID 生成的基准时间
+	TimeUnit         time.Duration // 时间戳的精度, 必须是毫秒的整数倍 (例如 1ms, 10ms, 1s)
+	TimestampBits    int           // 时间戳所占的位数, 为 0 时使用剩余的全部位数
+	DatacenterIdBits int           // 数据中心ID所占的位数
+	WorkerIdBits     int           // 机器ID所占的位数
+	SequenceBits     int           // 每个时间单位内序列号所占的位数
+	// SequenceAboveNode places the sequence above the datacenter and worker ID, as Sonyflake does.
+	// By default the sequence occupies the lowest bits, as in Twitter's layout.
+	SequenceAboveNode bool
+}
+
+var (
+	// DefaultLayout is the layout used by NewGenerator and NewWithDefault: millisecond resolution,
+	// 41 timestamp bits, 5 datacenter ID bits, 5 worker ID bits and 12 sequence bits since DefaultEpoch.
+	DefaultLayout = Layout{
+		Epoch:            DefaultEpoch,
+		TimeUnit:         time.Millisecond,
+		TimestampBits:    41,
+		DatacenterIdBits: DefaultDatacenterIdBits,
+		WorkerIdBits:     DefaultWorkerIdBits,
+		SequenceBits:     DefaultSequenceBits,
+	}
+
+	// TwitterLayout is the original Twitter Snowflake layout: millisecond resolution, 41 timestamp bits,
+	// 5 datacenter ID bits, 5 worker ID bits and 12 sequence bits since the Twitter epoch (2010-11-04 01:42:54.657 UTC).
+	TwitterLayout = Layout{
+		Epoch:            1288834974657,
+		TimeUnit:         time.Millisecond,
+		TimestampBits:    41,
+		DatacenterIdBits: 5,
+		WorkerIdBits:     5,
+		SequenceBits:     12,
+	}
+
+	// SonyflakeLayout is the Sonyflake layout: 10 millisecond resolution, 39 timestamp bits, 8 sequence bits
+	// and a 16-bit machine ID in the lowest bits, since 2014-09-01 00:00:00 UTC. The machine ID is the worker ID.
+	// It lasts about 174 years but generates at most 256 IDs per 10 milliseconds.
+	SonyflakeLayout = Layout{
+		Epoch:             1409529600000,
+		TimeUnit:          10 * time.Millisecond,
+		TimestampBits:     39,
+		WorkerIdBits:      16,
+		SequenceBits:      8,
+		SequenceAboveNode: true,
+	}
+)
+
+// BaiduEpoch is the default epoch of Baidu's UidGenerator, 2016-05-20 00:00:00 UTC+8, in Unix milliseconds.
+// Its 28 timestamp bits ran out in 2024, so use it only to parse the IDs of deployments that kept it.
+const BaiduEpoch int64 = 1463673600000
+
+// NewBaiduLayout returns the bit layout of Baidu's UidGenerator with the epoch of a deployment
+// (its epochStr setting): second resolution, 28 timestamp bits, 22 worker ID bits and 13 sequence bits.
+// It generates at most 8192 IDs per second and lasts about 8.5 years from epoch, which is truncated
+// to a whole second. Pass time.UnixMilli(BaiduEpoch) to parse the IDs of a deployment using the default epoch.
+func NewBaiduLayout(epoch time.Time) Layout {
+	return Layout{
+		Epoch:         epoch.Truncate(time.Second).UnixMilli(),
+		TimeUnit:      time.Second,
+		TimestampBits: 28,
+		WorkerIdBits:  22,
+		SequenceBits:  13,
+	}
+}
+
+// Validate checks that the time unit is a whole number of milliseconds, that the epoch is a whole number
+// of time units and that the bit widths fit into 63 bits.
+func (l Layout) Validate() error {
+	if l.TimeUnit < time.Millisecond || l.TimeUnit%time.Millisecond != 0 {
+		return fmt.Errorf("time unit must be a positive multiple of 1ms, got %s", l.TimeUnit)
+	}
+	if l.TimestampBits < 0 || l.DatacenterIdBits < 0 || l.WorkerIdBits < 0 || l.SequenceBits < 0 {
+		return fmt.Errorf("bit widths can't be negative: %+v", l)
+	}
+	if l.Epoch < 0 || l.Epoch%l.TimeUnit.Milliseconds() != 0 {
+		return fmt.Errorf("epoch must be a non-negative multiple of the time unit %s, got %d", l.TimeUnit, l.Epoch)
+	}
+	total := l.TimestampBits + l.DatacenterIdBits + l.WorkerIdBits + l.SequenceBits
+	if l.TimestampBits == 0 {
+		// The timestamp takes the remaining bits and needs at least one.
+		total++
+	}
+	if total > 63 {
+		return fmt.Errorf("bit widths add up to %d, more than the 63 bits of a positive int64", total)
+	}
+	return nil
+}
+
+// MaxTime returns the last time that can be represented by the layout's timestamp bits.
+// It returns the zero time if TimestampBits is 0, as the width then depends on the generator's options.
+func (l Layout) MaxTime() time.Time {
+	if l.TimestampBits == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(l.Epoch).Add(time.Duration(int64(1)<<l.TimestampBits-1) * l.TimeUnit)
+}
@@ -0,0 +1,203 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayout_Validate(t *testing.T) {
+	for _, layout := range []Layout{DefaultLayout, TwitterLayout, SonyflakeLayout, NewBaiduLayout(time.UnixMilli(BaiduEpoch))} {
+		assert.NoError(t, layout.Validate())
+	}
+
+	invalid := []Layout{
+		{TimeUnit: 0, SequenceBits: 12},
+		{TimeUnit: 1500 * time.Microsecond, SequenceBits: 12},
+		{TimeUnit: time.Millisecond, SequenceBits: -1},
+		{Epoch: -1, TimeUnit: time.Millisecond},
+		{Epoch: 1005, TimeUnit: 10 * time.Millisecond},
+		{TimeUnit: time.Millisecond, TimestampBits: 42, WorkerIdBits: 10, SequenceBits: 12},
+		{TimeUnit: time.Millisecond, WorkerIdBits: 31, SequenceBits: 32},
+	}
+	for _, layout := range invalid {
+		assert.Error(t, layout.Validate(), "%+v", layout)
+		_, err := NewWithLayout(layout, 0, 0)
+		assert.Error(t, err, "%+v", layout)
+	}
+
+	// Extension bits must fit next to an explicit timestamp width.
+	_, err := NewWithLayout(TwitterLayout, 0, 0, WithExtensionBits(1))
+	assert.Error(t, err)
+	_, err = New(DefaultEpoch, 5, 5, 12, 0, 0, WithExtensionBits(1))
+	assert.NoError(t, err)
+}
+
+func TestLayout_MaxTime(t *testing.T) {
+	assert.Equal(t, time.UnixMilli(DefaultEpoch).Add((1<<41-1)*time.Millisecond), DefaultLayout.MaxTime())
+	assert.True(t, SonyflakeLayout.MaxTime().After(time.Date(2180, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2024, 11, 20, 13, 24, 15, 0, time.UTC), NewBaiduLayout(time.UnixMilli(BaiduEpoch)).MaxTime().UTC())
+	assert.True(t, (Layout{TimeUnit: time.Millisecond}).MaxTime().IsZero())
+}
+
+func TestLayout_MaxTime_Presets(t *testing.T) {
+	// Every exported preset must last well beyond a fixed reference time, so the test doesn't start failing one day.
+	reference := time.Date(2060, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, layout := range []Layout{DefaultLayout, TwitterLayout, SonyflakeLayout} {
+		assert.True(t, layout.MaxTime().After(reference), "%+v expires at %s", layout, layout.MaxTime())
+	}
+}
+
+func TestNewBaiduLayout(t *testing.T) {
+	epoch := time.Date(2024, 3, 1, 8, 0, 0, 500, time.UTC)
+	layout := NewBaiduLayout(epoch)
+	assert.Equal(t, epoch.Truncate(time.Second).UnixMilli(), layout.Epoch)
+	assert.True(t, epoch.Truncate(time.Second).Add((1<<28-1)*time.Second).Equal(layout.MaxTime()))
+
+	// IDs of a UidGenerator deployment with the default epoch decode with BaiduEpoch:
+	// delta seconds << 35 | worker ID << 13 | sequence.
+	gen, err := NewWithLayout(NewBaiduLayout(time.UnixMilli(BaiduEpoch)), 0, 0)
+	assert.NoError(t, err)
+	parts := gen.Parse(int64(100_000_000)<<35 | 12345<<13 | 42)
+	assert.Equal(t, time.UnixMilli(BaiduEpoch).Add(100_000_000*time.Second), parts.Time)
+	assert.Equal(t, int64(12345), parts.WorkerId)
+	assert.Equal(t, int64(42), parts.Sequence)
+}
+
+func TestNewWithLayout_Presets(t *testing.T) {
+	tests := []struct {
+		name         string
+		layout       Layout
+		workerId     int64
+		datacenterId int64
+	}{
+		{"Twitter", TwitterLayout, 31, 7},
+		{"Sonyflake", SonyflakeLayout, 0xBEEF, 0},
+		{"Baidu", NewBaiduLayout(time.Now().Add(-time.Hour)), 1<<22 - 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewWithLayout(tt.layout, tt.workerId, tt.datacenterId)
+			assert.NoError(t, err)
+
+			before := time.Now().Truncate(tt.layout.TimeUnit)
+			id, err := gen.NextId()
+			assert.NoError(t, err)
+			assert.Greater(t, id, int64(0))
+
+			parts := gen.Parse(id)
+			assert.Equal(t, tt.workerId, parts.WorkerId)
+			assert.Equal(t, tt.datacenterId, parts.DatacenterId)
+			assert.Equal(t, int64(0), parts.Sequence)
+			assert.False(t, parts.Time.Before(before.Add(-tt.layout.TimeUnit)))
+			assert.False(t, parts.Time.After(time.Now()))
+			assert.Zero(t, parts.Time.UnixMilli()%tt.layout.TimeUnit.Milliseconds())
+		})
+	}
+}
+
+func TestNewWithLayout_Sonyflake(t *testing.T) {
+	clock := mockClock(0)
+	gen, err := NewWithLayout(SonyflakeLayout, 0x1234, 0, WithClock(clock))
+	assert.NoError(t, err)
+
+	// All IDs within the same 10 milliseconds share the timestamp and have increasing sequences above the machine ID.
+	id1, err := gen.NextId()
+	assert.NoError(t, err)
+	clock.Add(9)
+	id2, err := gen.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, (mockBase/10-SonyflakeLayout.Epoch/10)<<24|0<<16|0x1234, id1)
+	assert.Equal(t, id1+1<<16, id2)
+
+	// The next time unit resets the sequence.
+	clock.Add(1)
+	id3, err := gen.NextId()
+	assert.NoError(t, err)
+	parts := gen.Parse(id3)
+	assert.Equal(t, gen.Parse(id1).Timestamp+1, parts.Timestamp)
+	assert.Equal(t, int64(0), parts.Sequence)
+	assert.Equal(t, time.UnixMilli(mockBase+10), parts.Time)
+
+	// Batches step the sequence above the machine ID.
+	ids, err := gen.NextIds(3)
+	assert.NoError(t, err)
+	for i, id := range ids {
+		assert.Equal(t, int64(i+1), gen.Parse(id).Sequence)
+		assert.Equal(t, int64(0x1234), gen.Parse(id).WorkerId)
+	}
+}
+
+func TestNewWithLayout_SecondResolution(t *testing.T) {
+	clock := mockClock(0)
+	layout := Layout{Epoch: 1600000000000, TimeUnit: time.Second, TimestampBits: 31, WorkerIdBits: 10, SequenceBits: 2}
+	gen, err := NewWithLayout(layout, 5, 0, WithClock(clock))
+	assert.NoError(t, err)
+
+	// The sequence of a second is exhausted after 4 IDs; the fifth waits for the next second.
+	for i := 0; i < 4; i++ {
+		_, err = gen.NextId()
+		assert.NoError(t, err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		clock.Add(1000)
+	}()
+	id, err := gen.NextId()
+	assert.NoError(t, err)
+	parts := gen.Parse(id)
+	assert.Equal(t, (mockBase-layout.Epoch)/1000+1, parts.Timestamp)
+	assert.Equal(t, int64(0), parts.Sequence)
+	assert.Equal(t, int64(5), parts.WorkerId)
+
+	// Rollback errors report milliseconds.
+	clock.Store(-1000)
+	_, err = gen.NextId()
+	assert.ErrorIs(t, err, ErrClockMovedBackwards)
+	assert.Contains(t, err.Error(), "2000 milliseconds")
+}
+
+func TestNewWithLayout_TimestampOverflow(t *testing.T) {
+	layout := Layout{Epoch: mockBase - 1<<10, TimeUnit: time.Millisecond, TimestampBits: 10, SequenceBits: 12}
+	gen, err := NewWithLayout(layout, 0, 0, WithClock(mockClock(0)))
+	assert.NoError(t, err)
+
+	_, err = gen.NextId()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outside the 10 timestamp bits")
+}
+
+func TestNewAtomicWithLayout(t *testing.T) {
+	clock := mockClock(0)
+	gen, err := NewAtomicGenerator(WithLayout(SonyflakeLayout), WithWorkerId(0x1234), WithClock(clock))
+	assert.NoError(t, err)
+	reference, err := NewWithLayout(SonyflakeLayout, 0x1234, 0, WithClock(clock))
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		id, err := gen.NextId()
+		assert.NoError(t, err)
+		expected, err := reference.NextId()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, id)
+		clock.Add(4)
+	}
+}
+
+func TestNewAtomicWithLayout_TimestampOverflow(t *testing.T) {
+	clock := mockClock(0)
+	layout := Layout{Epoch: mockBase - 1000, TimeUnit: time.Millisecond, TimestampBits: 10, SequenceBits: 12}
+	gen, err := NewAtomicGenerator(WithLayout(layout), WithClock(clock))
+	assert.NoError(t, err)
+
+	id, err := gen.NextId()
+	assert.NoError(t, err)
+	assert.Greater(t, id, int64(0))
+
+	// Past the last time of the layout the timestamp would wrap into the sign bit.
+	clock.Store(layout.MaxTime().UnixMilli() - mockBase + 1)
+	_, err = gen.NextId()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outside the 10 timestamp bits")
+}
@@ -0,0 +1,246 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLeaseLost is returned when the worker ID lease has expired or was taken over by another owner.
+	ErrLeaseLost = errors.New("worker ID lease lost")
+
+	// DefaultLeaseTTL is the default time a worker ID lease stays valid without being renewed.
+	DefaultLeaseTTL = 30 * time.Second
+
+	// DefaultLeaseTable is the default name of the table holding the worker ID leases.
+	DefaultLeaseTable = "snowflake_worker_leases"
+)
+
+// WorkerLease is a row of the worker ID lease table.
+type WorkerLease struct {
+	NodeId    int64     `gorm:"primaryKey;autoIncrement:false"` // 节点编号 (datacenterId << workerIdBits | workerId)
+	Owner     string    `gorm:"size:255;not null"`              // 租约持有者标识
+	ExpiresAt time.Time `gorm:"not null"`                       // 租约过期时间
+}
+
+// LeaseOption is a function type used to configure a LeaseWorkerIDProvider.
+type LeaseOption func(p *LeaseWorkerIDProvider)
+
+// WithLeaseTTL returns a LeaseOption that sets how long a lease stays valid without being renewed.
+// The lease is renewed every third of the TTL. The TTL should be well above the clock skew between hosts.
+func WithLeaseTTL(ttl time.Duration) LeaseOption {
+	return func(p *LeaseWorkerIDProvider) {
+		p.ttl = ttl
+	}
+}
+
+// WithLeaseTable returns a LeaseOption that sets the name of the lease table.
+func WithLeaseTable(table string) LeaseOption {
+	return func(p *LeaseWorkerIDProvider) {
+		p.table = table
+	}
+}
+
+// WithLeaseOwner returns a LeaseOption that sets the owner identity stored with the lease.
+// It defaults to the host name, process ID and a random suffix.
+func WithLeaseOwner(owner string) LeaseOption {
+	return func(p *LeaseWorkerIDProvider) {
+		p.owner = owner
+	}
+}
+
+// LeaseWorkerIDProvider is a WorkerIDProvider that leases a free worker ID from a database table.
+// The lease is renewed in the background with a heartbeat. If it can't be renewed before it expires,
+// or another owner has taken it over, CheckLease reports ErrLeaseLost and generators created with
+// NewWithProvider refuse to generate IDs.
+// Use a *gorm.DB created by the database package, e.g. database.NewDB.
+type LeaseWorkerIDProvider struct {
+	db    *gorm.DB
+	table string
+	ttl   time.Duration
+	owner string
+
+	nodeId    int64        // 已获取租约的节点编号
+	expiresAt atomic.Int64 // 本地记录的租约过期时间 (UnixNano)
+	lost      atomic.Bool  // 租约是否已被其他持有者接管
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewLeaseWorkerIDProvider creates a new LeaseWorkerIDProvider backed by db.
+// The lease table is created if it doesn't exist when a worker ID is requested.
+func NewLeaseWorkerIDProvider(db *gorm.DB, opts ...LeaseOption) *LeaseWorkerIDProvider {
+	p := &LeaseWorkerIDProvider{
+		db:    db,
+		table: DefaultLeaseTable,
+		ttl:   DefaultLeaseTTL,
+		owner: defaultLeaseOwner(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// WorkerID acquires a lease on a free worker ID and starts the heartbeat that renews it.
+// A worker ID is free if it has never been leased or its lease has expired.
+// It must be called only once per provider.
+func (p *LeaseWorkerIDProvider) WorkerID(ctx context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+	if p.ttl <= 0 {
+		return 0, 0, fmt.Errorf("lease TTL must be positive, got %s", p.ttl)
+	}
+	db := p.db.WithContext(ctx)
+	if err := db.Table(p.table).AutoMigrate(&WorkerLease{}); err != nil {
+		return 0, 0, fmt.Errorf("failed to migrate lease table %s: %w", p.table, err)
+	}
+
+	var leases []WorkerLease
+	if err := db.Table(p.table).Find(&leases).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to load worker ID leases: %w", err)
+	}
+	taken := make(map[int64]WorkerLease, len(leases))
+	for _, lease := range leases {
+		taken[lease.NodeId] = lease
+	}
+
+	// Prefer worker IDs that were never leased, then take over expired leases.
+	nodes := (maxWorkerId + 1) * (maxDatacenterId + 1)
+	for nodeId := int64(0); nodeId < nodes; nodeId++ {
+		if _, ok := taken[nodeId]; ok {
+			continue
+		}
+		expiresAt := time.Now().Add(p.ttl)
+		result := db.Table(p.table).Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&WorkerLease{NodeId: nodeId, Owner: p.owner, ExpiresAt: expiresAt})
+		if result.Error != nil {
+			return 0, 0, fmt.Errorf("failed to create worker ID lease: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return p.acquired(nodeId, expiresAt, maxWorkerId, maxDatacenterId)
+		}
+	}
+	for nodeId := int64(0); nodeId < nodes; nodeId++ {
+		if lease, ok := taken[nodeId]; !ok || lease.ExpiresAt.After(time.Now()) {
+			continue
+		}
+		expiresAt := time.Now().Add(p.ttl)
+		result := db.Table(p.table).
+			Where("node_id = ? AND expires_at < ?", nodeId, time.Now()).
+			Updates(map[string]any{"owner": p.owner, "expires_at": expiresAt})
+		if result.Error != nil {
+			return 0, 0, fmt.Errorf("failed to take over worker ID lease: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return p.acquired(nodeId, expiresAt, maxWorkerId, maxDatacenterId)
+		}
+	}
+	return 0, 0, fmt.Errorf("no free worker ID among %d in table %s", nodes, p.table)
+}
+
+// acquired records the acquired lease and starts the heartbeat.
+func (p *LeaseWorkerIDProvider) acquired(nodeId int64, expiresAt time.Time, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+	p.nodeId = nodeId
+	p.expiresAt.Store(expiresAt.UnixNano())
+	go p.heartbeat()
+
+	workerId, datacenterId := splitNodeId(uint64(nodeId), maxWorkerId, maxDatacenterId)
+	slog.Info("Acquired snowflake worker ID lease", "nodeId", nodeId, "workerId", workerId,
+		"datacenterId", datacenterId, "owner", p.owner)
+	return workerId, datacenterId, nil
+}
+
+// heartbeat renews the lease every third of the TTL until Close is called or the lease is lost.
+func (p *LeaseWorkerIDProvider) heartbeat() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.renew(); err != nil {
+				slog.Error("Failed to renew snowflake worker ID lease", "nodeId", p.nodeId, "error", err)
+				if errors.Is(err, ErrLeaseLost) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// renew extends the lease if it is still owned by this provider.
+func (p *LeaseWorkerIDProvider) renew() error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ttl/3)
+	defer cancel()
+
+	expiresAt := time.Now().Add(p.ttl)
+	result := p.db.WithContext(ctx).Table(p.table).
+		Where("node_id = ? AND owner = ?", p.nodeId, p.owner).
+		Update("expires_at", expiresAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		p.lost.Store(true)
+		return fmt.Errorf("%w: node %d is owned by someone else", ErrLeaseLost, p.nodeId)
+	}
+	p.expiresAt.Store(expiresAt.UnixNano())
+	return nil
+}
+
+// CheckLease returns ErrLeaseLost if the lease was taken over or has expired without being renewed.
+func (p *LeaseWorkerIDProvider) CheckLease() error {
+	if p.lost.Load() {
+		return fmt.Errorf("%w: node %d is owned by someone else", ErrLeaseLost, p.nodeId)
+	}
+	if time.Now().UnixNano() >= p.expiresAt.Load() {
+		return fmt.Errorf("%w: lease of node %d expired", ErrLeaseLost, p.nodeId)
+	}
+	return nil
+}
+
+// Close stops the heartbeat and releases the lease so the worker ID can be reused immediately.
+// Generators using the provider can't generate IDs afterwards.
+func (p *LeaseWorkerIDProvider) Close() error {
+	var err error
+	p.once.Do(func() {
+		close(p.stop)
+		if p.expiresAt.Load() == 0 {
+			return // No lease was acquired.
+		}
+		<-p.done
+		p.lost.Store(true)
+		err = p.db.Table(p.table).Where("node_id = ? AND owner = ?", p.nodeId, p.owner).Delete(&WorkerLease{}).Error
+		if err != nil {
+			err = fmt.Errorf("failed to release worker ID lease: %w", err)
+		}
+	})
+	return err
+}
+
+// defaultLeaseOwner returns an owner identity made of the host name, process ID and a random suffix.
+func defaultLeaseOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,129 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseWorkerIDProvider_Acquire(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// Every provider gets a different worker ID while the leases are held.
+	seen := make(map[[2]int64]struct{})
+	for i := 0; i < 4; i++ {
+		provider := NewLeaseWorkerIDProvider(db, WithLeaseOwner("owner-"+string(rune('a'+i))))
+		t.Cleanup(func() { _ = provider.Close() })
+
+		workerId, datacenterId, err := provider.WorkerID(ctx, 1, 1)
+		assert.NoError(t, err)
+		_, exists := seen[[2]int64{workerId, datacenterId}]
+		assert.False(t, exists, "Duplicate worker ID leased: %d/%d", workerId, datacenterId)
+		seen[[2]int64{workerId, datacenterId}] = struct{}{}
+		assert.NoError(t, provider.CheckLease())
+	}
+
+	// All 4 worker IDs are taken now.
+	_, _, err := NewLeaseWorkerIDProvider(db).WorkerID(ctx, 1, 1)
+	assert.Error(t, err)
+}
+
+func TestLeaseWorkerIDProvider_TakeOverExpired(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	assert.NoError(t, db.Table(DefaultLeaseTable).AutoMigrate(&WorkerLease{}))
+	assert.NoError(t, db.Table(DefaultLeaseTable).Create(&WorkerLease{NodeId: 0, Owner: "crashed", ExpiresAt: time.Now().Add(-time.Minute)}).Error)
+	assert.NoError(t, db.Table(DefaultLeaseTable).Create(&WorkerLease{NodeId: 1, Owner: "alive", ExpiresAt: time.Now().Add(time.Minute)}).Error)
+
+	provider := NewLeaseWorkerIDProvider(db, WithLeaseOwner("new"))
+	defer func() { _ = provider.Close() }()
+	workerId, datacenterId, err := provider.WorkerID(ctx, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), workerId)
+	assert.Equal(t, int64(0), datacenterId)
+
+	var lease WorkerLease
+	assert.NoError(t, db.Table(DefaultLeaseTable).First(&lease, "node_id = ?", 0).Error)
+	assert.Equal(t, "new", lease.Owner)
+}
+
+func TestLeaseWorkerIDProvider_HeartbeatAndLoss(t *testing.T) {
+	db := newTestDB(t)
+
+	provider := NewLeaseWorkerIDProvider(db, WithLeaseTTL(300*time.Millisecond), WithLeaseOwner("me"))
+	defer func() { _ = provider.Close() }()
+	id, err := NewWithProvider(context.Background(), provider)
+	assert.NoError(t, err)
+
+	// The heartbeat keeps the lease alive beyond its TTL.
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, provider.CheckLease())
+	_, err = id.NextId()
+	assert.NoError(t, err)
+
+	// Another owner takes over the lease; the next renewal notices and the generator stops.
+	assert.NoError(t, db.Table(DefaultLeaseTable).Where("node_id = ?", 0).Update("owner", "thief").Error)
+	assert.Eventually(t, func() bool {
+		return provider.CheckLease() != nil
+	}, time.Second, 20*time.Millisecond)
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrLeaseLost))
+}
+
+func TestLeaseWorkerIDProvider_Close(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	provider := NewLeaseWorkerIDProvider(db, WithLeaseOwner("first"))
+	_, _, err := provider.WorkerID(ctx, 0, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, provider.Close())
+	assert.True(t, errors.Is(provider.CheckLease(), ErrLeaseLost))
+	assert.NoError(t, provider.Close(), "Close should be idempotent")
+
+	// The released worker ID can be leased again right away.
+	other := NewLeaseWorkerIDProvider(db, WithLeaseOwner("second"))
+	defer func() { _ = other.Close() }()
+	_, _, err = other.WorkerID(ctx, 0, 0)
+	assert.NoError(t, err)
+
+	// Closing a provider that never acquired a lease is fine.
+	assert.NoError(t, NewLeaseWorkerIDProvider(db).Close())
+}
+
+func TestLeaseWorkerIDProvider_ReleasedOnGeneratorError(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	leases := func() int64 {
+		var count int64
+		if db.Migrator().HasTable(DefaultLeaseTable) {
+			assert.NoError(t, db.Table(DefaultLeaseTable).Count(&count).Error)
+		}
+		return count
+	}
+
+	// The lock-free generator rejects the provider before it acquires a lease.
+	provider := NewLeaseWorkerIDProvider(db, WithLeaseOwner("atomic"))
+	_, err := NewAtomicGenerator(WithWorkerIDProvider(ctx, provider))
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), leases())
+	assert.Zero(t, provider.expiresAt.Load(), "No lease should be acquired")
+
+	// A generator that fails after the lease was acquired releases it and stops the heartbeat.
+	provider = NewLeaseWorkerIDProvider(db, WithLeaseOwner("invalid"))
+	_, err = NewGenerator(WithWorkerIDProvider(ctx, provider), WithRollbackPolicy(RollbackExtension, time.Second))
+	assert.Error(t, err)
+	assert.NotZero(t, provider.expiresAt.Load(), "The lease should have been acquired")
+	assert.Equal(t, int64(0), leases())
+	select {
+	case <-provider.done:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat is still running")
+	}
+	assert.True(t, errors.Is(provider.CheckLease(), ErrLeaseLost))
+}
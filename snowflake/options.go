@@ -0,0 +1,86 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Option is a function type used to configure a DistributeId instance.
+// It follows the functional options pattern and is applied by NewGenerator and the other constructors.
+type Option func(*DistributeId) error
+
+// WithRollbackPolicy returns an Option that sets how the generator reacts to the system clock moving backwards.
+// tolerance is the largest rollback that RollbackWait waits out and the furthest RollbackBorrow runs ahead
+// of the clock; larger rollbacks are rejected. It is ignored by RollbackFail and RollbackExtension.
+func WithRollbackPolicy(policy RollbackPolicy, tolerance time.Duration) Option {
+	return func(d *DistributeId) error {
+		if policy < RollbackFail || policy > RollbackExtension {
+			return fmt.Errorf("unknown rollback policy: %d", policy)
+		}
+		if tolerance < 0 {
+			return fmt.Errorf("rollback tolerance can't be negative: %s", tolerance)
+		}
+		d.rollbackPolicy = policy
+		d.rollbackTolerance = tolerance
+		return nil
+	}
+}
+
+// WithExtensionBits returns an Option that reserves bits between the timestamp and the datacenter ID
+// for a clock rollback extension, as used by RollbackExtension.
+// The reserved bits reduce the bits left for the timestamp, so keep them small (1 to 8 bits).
+func WithExtensionBits(bits int) Option {
+	return func(d *DistributeId) error {
+		if bits < 0 || bits > 8 {
+			return fmt.Errorf("extension bits must be between 0 and 8, got %d", bits)
+		}
+		d.extensionBits = bits
+		return nil
+	}
+}
+
+// WithClock returns an Option that sets the clock the generator reads the time from.
+// It defaults to SystemClock; tests can inject a fake clock.
+func WithClock(clock Clock) Option {
+	return func(d *DistributeId) error {
+		if clock == nil {
+			return errors.New("clock is nil")
+		}
+		d.clock = clock
+		return nil
+	}
+}
+
+// WithLayout returns an Option that sets the bit layout and time unit of the IDs, including the epoch.
+// It replaces the whole layout, so apply WithEpoch after it to change only the epoch.
+func WithLayout(layout Layout) Option {
+	return func(d *DistributeId) error {
+		d.layout = layout
+		return nil
+	}
+}
+
+// WithEpoch returns an Option that sets the starting time the timestamps of the IDs are relative to.
+func WithEpoch(epoch time.Time) Option {
+	return func(d *DistributeId) error {
+		d.layout.Epoch = epoch.UnixMilli()
+		return nil
+	}
+}
+
+// WithWorkerId returns an Option that sets the worker ID of the generator.
+func WithWorkerId(workerId int64) Option {
+	return func(d *DistributeId) error {
+		d.workerId = workerId
+		return nil
+	}
+}
+
+// WithDatacenterId returns an Option that sets the datacenter ID of the generator.
+func WithDatacenterId(datacenterId int64) Option {
+	return func(d *DistributeId) error {
+		d.datacenterId = datacenterId
+		return nil
+	}
+}
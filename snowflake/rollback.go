@@ -0,0 +1,123 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrClockMovedBackwards is returned when the system clock moved backwards and the
+// rollback policy does not allow generating an ID.
+var ErrClockMovedBackwards = errors.New("clock moved backwards")
+
+// RollbackPolicy determines how a DistributeId reacts when the system clock moves backwards,
+// for example after an NTP adjustment.
+type RollbackPolicy int
+
+const (
+	// RollbackFail refuses to generate IDs until the clock has caught up again. This is the default.
+	RollbackFail RollbackPolicy = iota
+	// RollbackWait blocks until the clock has caught up, as long as the rollback is within the tolerance.
+	RollbackWait
+	// RollbackBorrow keeps generating IDs from the last timestamp and moves it ahead of the clock
+	// when the sequence is exhausted, as long as it stays within the tolerance.
+	RollbackBorrow
+	// RollbackExtension switches to an unused value of the extension bits and continues with the
+	// current clock. It requires extension bits to be reserved with WithExtensionBits.
+	RollbackExtension
+)
+
+// String returns the name of the rollback policy.
+func (p RollbackPolicy) String() string {
+	switch p {
+	case RollbackFail:
+		return "fail"
+	case RollbackWait:
+		return "wait"
+	case RollbackBorrow:
+		return "borrow"
+	case RollbackExtension:
+		return "extension"
+	default:
+		return fmt.Sprintf("RollbackPolicy(%d)", int(p))
+	}
+}
+
+// RollbackCount returns the number of clock rollback events detected by the generator.
+// A rollback that lasts across several NextId calls is counted once.
+// It is safe to call concurrently and is intended for monitoring and alerting. It equals Stats().Rollbacks.
+func (d *DistributeId) RollbackCount() int64 {
+	return d.rollbacks.Load()
+}
+
+// handleRollback applies the rollback policy when timestamp is behind lastTimestamp.
+// It returns the timestamp to generate the next ID with, or an error if the ID must not be generated.
+// The caller must hold the lock.
+func (d *DistributeId) handleRollback(timestamp int64) (int64, error) {
+	if !d.rollingBack {
+		d.rollingBack = true
+		d.rollbacks.Add(1)
+	}
+
+	switch d.rollbackPolicy {
+	case RollbackWait:
+		// Sleep until the clock catches up, re-checking in case it moved back even further.
+		for timestamp < d.lastTimestamp {
+			offset := d.lastTimestamp - timestamp
+			if d.units(offset) > d.rollbackTolerance {
+				return 0, d.rollbackError(offset)
+			}
+			time.Sleep(d.units(offset))
+			timestamp = d.timeGen()
+		}
+		d.rollingBack = false
+		return timestamp, nil
+	case RollbackBorrow:
+		// Continue with the sequence of the last timestamp; nextTimestamp moves it ahead when exhausted.
+		if offset := d.lastTimestamp - timestamp; d.units(offset) > d.rollbackTolerance {
+			return 0, d.rollbackError(offset)
+		}
+		return d.lastTimestamp, nil
+	case RollbackExtension:
+		// Pick the next extension value that has never been used at or after this timestamp,
+		// so the IDs generated from now on can't collide with IDs generated before the rollback.
+		mask := int64(len(d.extensionHigh) - 1)
+		for i := int64(1); i <= mask; i++ {
+			extension := (d.extension + i) & mask
+			if d.extensionHigh[extension] < timestamp {
+				d.extension = extension
+				d.rollingBack = false
+				// Start a fresh sequence under the new extension value.
+				d.lastTimestamp = timestamp - 1
+				return timestamp, nil
+			}
+		}
+		return 0, fmt.Errorf("%w. No free extension value for a rollback of %d milliseconds",
+			ErrClockMovedBackwards, d.units(d.lastTimestamp-timestamp).Milliseconds())
+	default:
+		return 0, d.rollbackError(d.lastTimestamp - timestamp)
+	}
+}
+
+// nextTimestamp returns the timestamp to use once the sequence of lastTimestamp is exhausted.
+// While borrowing during a rollback, it moves one time unit ahead without waiting for the clock.
+// The caller must hold the lock.
+func (d *DistributeId) nextTimestamp(lastTimestamp int64) (int64, error) {
+	if d.rollingBack && d.rollbackPolicy == RollbackBorrow {
+		if ahead := lastTimestamp + 1 - d.timeGen(); d.units(ahead) > d.rollbackTolerance {
+			return 0, d.rollbackError(ahead)
+		}
+		return lastTimestamp + 1, nil
+	}
+	return d.tilNextMillis(lastTimestamp), nil
+}
+
+// rollbackError returns the error reported when an ID is refused because of a clock rollback of offset time units.
+func (d *DistributeId) rollbackError(offset int64) error {
+	return fmt.Errorf("%w. Refusing to generate ID for %d milliseconds", ErrClockMovedBackwards, d.units(offset).Milliseconds())
+}
+
+// units converts a number of time units of the layout into a duration.
+func (d *DistributeId) units(n int64) time.Duration {
+	return time.Duration(n*d.timeUnit) * time.Millisecond
+}
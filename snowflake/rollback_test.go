@@ -0,0 +1,181 @@
+package snowflake
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockBase is a fixed point in time after DefaultEpoch used as the origin of the mocked clocks.
+const mockBase = 1700000000000
+
+// mockClock returns a controllable Clock starting at mockBase + start milliseconds.
+func mockClock(start int64) *mockTime {
+	now := &mockTime{}
+	now.Store(start)
+	return now
+}
+
+// mockTime is a Clock whose value is held in milliseconds relative to mockBase.
+type mockTime struct {
+	atomic.Int64
+}
+
+// Now implements Clock.
+func (m *mockTime) Now() time.Time {
+	return time.UnixMilli(mockBase + m.Load())
+}
+
+func TestRollbackPolicy_Fail(t *testing.T) {
+	now := mockClock(1000)
+	id, err := NewWithDefault(0, 0, WithClock(now))
+	assert.NoError(t, err)
+
+	_, err = id.NextId()
+	assert.NoError(t, err)
+
+	now.Store(995)
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+	_, err = id.NextId()
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), id.RollbackCount(), "A continuing rollback should be counted once")
+
+	// Once the clock has caught up, IDs are generated again.
+	now.Store(1001)
+	_, err = id.NextId()
+	assert.NoError(t, err)
+
+	now.Store(990)
+	_, err = id.NextId()
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), id.RollbackCount())
+}
+
+func TestRollbackPolicy_Wait(t *testing.T) {
+	now := mockClock(1000)
+	id, err := NewWithDefault(0, 0, WithClock(now), WithRollbackPolicy(RollbackWait, 50*time.Millisecond))
+	assert.NoError(t, err)
+
+	first, err := id.NextId()
+	assert.NoError(t, err)
+
+	// A small rollback is waited out.
+	now.Store(990)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		now.Store(1002)
+	}()
+	second, err := id.NextId()
+	assert.NoError(t, err)
+	assert.Greater(t, second, first)
+	assert.Equal(t, int64(mockBase+1002), id.Parse(second).Time.UnixMilli())
+	assert.Equal(t, int64(1), id.RollbackCount())
+
+	// A rollback beyond the tolerance fails immediately.
+	now.Store(900)
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+	assert.Equal(t, int64(2), id.RollbackCount())
+}
+
+func TestRollbackPolicy_Borrow(t *testing.T) {
+	now := mockClock(1000)
+	layout := DefaultLayout
+	layout.SequenceBits = 2
+	id, err := NewGenerator(WithLayout(layout), WithClock(now), WithRollbackPolicy(RollbackBorrow, 3*time.Millisecond))
+	assert.NoError(t, err)
+
+	last, err := id.NextId()
+	assert.NoError(t, err)
+
+	// While the clock is behind, IDs keep increasing by borrowing ahead of the clock.
+	now.Store(999)
+	for i := 0; i < 11; i++ {
+		next, err := id.NextId()
+		assert.NoError(t, err)
+		assert.Greater(t, next, last)
+		last = next
+	}
+	assert.Equal(t, int64(mockBase+1002), id.lastTimestamp)
+	assert.Equal(t, int64(1), id.RollbackCount())
+
+	// Borrowing further than the tolerance ahead of the clock is refused.
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+
+	// A rollback larger than the tolerance is refused as well.
+	now.Store(1003)
+	_, err = id.NextId()
+	assert.NoError(t, err)
+	now.Store(990)
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+}
+
+func TestRollbackPolicy_Extension(t *testing.T) {
+	// Extension policy requires extension bits.
+	_, err := NewWithDefault(0, 0, WithRollbackPolicy(RollbackExtension, 0))
+	assert.Error(t, err)
+	_, err = NewWithDefault(0, 0, WithExtensionBits(9))
+	assert.Error(t, err)
+
+	now := mockClock(1000)
+	id, err := NewWithDefault(3, 4, WithClock(now), WithRollbackPolicy(RollbackExtension, 0), WithExtensionBits(1))
+	assert.NoError(t, err)
+
+	generated := make(map[int64]struct{})
+	generate := func() (int64, error) {
+		newID, err := id.NextId()
+		if err == nil {
+			_, exists := generated[newID]
+			assert.False(t, exists, "Duplicate ID generated: %d", newID)
+			generated[newID] = struct{}{}
+		}
+		return newID, err
+	}
+
+	for now.Load() < 1005 {
+		_, err = generate()
+		assert.NoError(t, err)
+		now.Add(1)
+	}
+
+	// The first rollback switches to the other extension value and keeps generating.
+	now.Store(1001)
+	newID, err := generate()
+	assert.NoError(t, err)
+	parts := id.Parse(newID)
+	assert.Equal(t, int64(1), parts.Extension)
+	assert.Equal(t, int64(3), parts.WorkerId)
+	assert.Equal(t, int64(4), parts.DatacenterId)
+	assert.Equal(t, int64(mockBase+1001), parts.Time.UnixMilli())
+
+	// A second rollback overlapping both extension values has no free value left.
+	now.Store(1000)
+	_, err = generate()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+	assert.Equal(t, int64(2), id.RollbackCount())
+
+	// Once the clock has moved past everything generated under extension 0, it can be reused.
+	for now.Store(1002); now.Load() < 1010; now.Add(1) {
+		_, err = generate()
+		assert.NoError(t, err)
+	}
+	now.Store(1006)
+	newID, err = generate()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), id.Parse(newID).Extension)
+	assert.Equal(t, int64(3), id.RollbackCount())
+}
+
+func TestRollbackPolicy_String(t *testing.T) {
+	assert.Equal(t, "fail", RollbackFail.String())
+	assert.Equal(t, "wait", RollbackWait.String())
+	assert.Equal(t, "borrow", RollbackBorrow.String())
+	assert.Equal(t, "extension", RollbackExtension.String())
+	assert.Equal(t, "RollbackPolicy(9)", RollbackPolicy(9).String())
+}
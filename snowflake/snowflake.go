@@ -1,12 +1,15 @@
 // Package snowflake implements the Snowflake ID generation algorithm.
 // It generates unique, time-ordered, 64-bit IDs.
 // The ID structure is: timestamp (41 bits) + datacenter ID (5 bits) + worker ID (5 bits) + sequence (12 bits).
+// For IDs that need no worker ID coordination it also provides UUIDv7 and ULID generators.
 package snowflake
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,20 +37,47 @@ var (
 
 // DistributeId represents a Snowflake ID generator instance.
 // It holds the configuration and state required for ID generation.
+//
+// Timestamps are counted in time units of the layout (milliseconds by default) since the Unix epoch.
 type DistributeId struct {
-	epoch              int64       // 开始时间截 (毫秒), ID 生成的基准时间
+	epoch              int64       // 开始时间截 (时间单位), ID 生成的基准时间
+	timeUnit           int64       // 每个时间单位的毫秒数 (通常为 1)
+	timestampBits      int         // 时间戳所占的位数 (通常为 41)
 	workerIdBits       int         // 机器ID所占的位数 (通常为 5)
 	datacenterIdBits   int         // 数据中心ID所占的位数 (通常为 5)
-	sequenceBits       int         // 毫秒内序列号所占的位数 (通常为 12)
+	sequenceBits       int         // 时间单位内序列号所占的位数 (通常为 12)
 	workerId           int64       // 当前工作机器ID (0 ~ 2^workerIdBits - 1)
 	datacenterId       int64       // 当前数据中心ID (0 ~ 2^datacenterIdBits - 1)
+	sequenceShift      int         // 序列号在 ID 中左移的位数 (通常为 0)
 	workerIdShift      int         // 机器ID在 ID 中左移的位数 (sequenceBits)
 	datacenterIdShift  int         // 数据中心ID在 ID 中左移的位数 (sequenceBits + workerIdBits)
 	timestampLeftShift int         // 时间戳在 ID 中左移的位数 (datacenterIdShift + datacenterIdBits)
-	sequenceMask       int64       // 毫秒内序列号的最大值掩码 (2^sequenceBits - 1)
-	sequence           int64       // 毫秒内序列 (0 ~ sequenceMask)
-	lastTimestamp      int64       // 上次生成ID的时间截 (毫秒)
+	sequenceMask       int64       // 时间单位内序列号的最大值掩码 (2^sequenceBits - 1)
+	sequence           int64       // 时间单位内序列 (0 ~ sequenceMask)
+	lastTimestamp      int64       // 上次生成ID的时间截 (时间单位)
 	m                  *sync.Mutex // 互斥锁，用于保证并发安全
+	clock              Clock       // 时间来源 (默认 SystemClock)
+	layout             Layout      // 选项设置的位布局, 创建时据此计算上面的字段
+
+	rollbackPolicy    RollbackPolicy // 时钟回拨处理策略 (默认 RollbackFail)
+	rollbackTolerance time.Duration  // 可容忍的最大时钟回拨
+	extensionBits     int            // 时钟回拨扩展位所占的位数 (默认 0)
+	extensionShift    int            // 扩展位在 ID 中左移的位数 (datacenterIdShift + datacenterIdBits)
+	extension         int64          // 当前扩展位的值
+	extensionHigh     []int64        // 每个扩展位值已使用过的最大时间截 (时间单位)
+	rollingBack       bool           // 当前是否处于时钟回拨状态
+	rollbacks         atomic.Int64   // 检测到的时钟回拨事件次数
+	issued            atomic.Int64   // 已生成的ID数量
+	exhaustionWaits   atomic.Int64   // 序列号耗尽后等待的次数
+
+	lease       LeaseChecker     // 工作机器ID租约, 租约丢失后拒绝生成ID
+	provider    WorkerIDProvider // 创建时分配工作机器ID的提供者 (可选)
+	providerCtx context.Context  // 调用 provider 时使用的上下文
+	lockFree    bool             // 是否为无锁生成器创建, 创建时拒绝其不支持的选项
+
+	stateStore    StateStore    // 持久化高水位的存储 (可选)
+	stateReserve  time.Duration // 高水位相对于当前时间预留的时长
+	reservedUntil int64         // 已持久化的高水位 (时间单位)
 }
 
 // newDefault initializes the default DistributeId instance.
@@ -61,84 +91,263 @@ func newDefault() *DistributeId {
 }
 
 // NewWithDefault creates a new DistributeId instance with default epoch and bit lengths.
-// It takes workerId and datacenterId as parameters.
-func NewWithDefault(workerId int64, datacenterId int64) (*DistributeId, error) {
-	return New(DefaultEpoch, DefaultWorkerIdBits, DefaultDatacenterIdBits, DefaultSequenceBits, workerId, datacenterId)
+// It takes workerId and datacenterId as parameters, followed by optional Option functions.
+func NewWithDefault(workerId int64, datacenterId int64, opts ...Option) (*DistributeId, error) {
+	return NewGenerator(append([]Option{WithWorkerId(workerId), WithDatacenterId(datacenterId)}, opts...)...)
 }
 
 // New creates a new DistributeId instance with custom configurations.
-// It validates the workerId and datacenterId against their maximum allowed values.
-func New(epoch int64, workerIdBits int, datacenterIdBits int, sequenceBits int, workerId int64, datacenterId int64) (*DistributeId, error) {
+// It uses millisecond resolution and gives the timestamp all bits not used by the other components.
+// It validates the workerId and datacenterId against their maximum allowed values
+// and applies the optional Option functions, such as WithRollbackPolicy.
+//
+// Deprecated: Use NewGenerator with WithEpoch, WithLayout, WithWorkerId and WithDatacenterId instead.
+func New(epoch int64, workerIdBits int, datacenterIdBits int, sequenceBits int, workerId int64, datacenterId int64, opts ...Option) (*DistributeId, error) {
+	layout := Layout{
+		Epoch:            epoch,
+		TimeUnit:         time.Millisecond,
+		DatacenterIdBits: datacenterIdBits,
+		WorkerIdBits:     workerIdBits,
+		SequenceBits:     sequenceBits,
+	}
+	return NewWithLayout(layout, workerId, datacenterId, opts...)
+}
+
+// NewWithLayout creates a new DistributeId instance that generates IDs with the given layout,
+// for example SonyflakeLayout or NewBaiduLayout to generate IDs compatible with other systems.
+// It validates the layout, the workerId and datacenterId, and applies the optional Option functions.
+func NewWithLayout(layout Layout, workerId int64, datacenterId int64, opts ...Option) (*DistributeId, error) {
+	return NewGenerator(append([]Option{WithLayout(layout), WithWorkerId(workerId), WithDatacenterId(datacenterId)}, opts...)...)
+}
+
+// NewGenerator creates a new DistributeId instance configured by functional options.
+// Without options it uses DefaultLayout, with the timestamp taking the bits left over by optional
+// extension bits, worker ID 0, datacenter ID 0 and SystemClock. It validates the resulting configuration.
+func NewGenerator(opts ...Option) (_ *DistributeId, err error) {
+	id := &DistributeId{
+		layout: DefaultLayout,
+		clock:  SystemClock,
+		m:      &sync.Mutex{},
+	}
+	id.layout.TimestampBits = 0
+
+	// Apply the optional configurations.
+	for _, opt := range opts {
+		if err := opt(id); err != nil {
+			return nil, fmt.Errorf("failed to apply snowflake option: %w", err)
+		}
+	}
+
+	// The lock-free generator rejects leases before the provider acquires one.
+	if id.lockFree {
+		if err := id.checkLockFree(); err != nil {
+			return nil, err
+		}
+	}
+
+	layout := id.layout
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid snowflake layout: %w", err)
+	}
+	workerIdBits, datacenterIdBits, sequenceBits := layout.WorkerIdBits, layout.DatacenterIdBits, layout.SequenceBits
+
 	// Calculate maximum allowed values for workerId and datacenterId based on their bit lengths.
 	maxWorkerId := int64(-1 ^ (-1 << workerIdBits))
 	maxDatacenterId := int64(-1 ^ (-1 << datacenterIdBits))
 
+	// Let the provider assign the worker ID within the bit lengths of the layout.
+	if id.provider != nil {
+		if err := id.assignWorkerId(maxWorkerId, maxDatacenterId); err != nil {
+			return nil, err
+		}
+		// Release the assignment, e.g. a worker ID lease, if the generator can't be created after all.
+		defer func() {
+			if err != nil {
+				id.releaseWorkerId()
+			}
+		}()
+	}
+
 	// Validate workerId.
-	if workerId > maxWorkerId || workerId < 0 {
+	if id.workerId > maxWorkerId || id.workerId < 0 {
 		return nil, fmt.Errorf("worker ID can't be greater than %d or less than 0", maxWorkerId)
 	}
 	// Validate datacenterId.
-	if datacenterId > maxDatacenterId || datacenterId < 0 {
+	if id.datacenterId > maxDatacenterId || id.datacenterId < 0 {
 		return nil, fmt.Errorf("datacenter ID can't be greater than %d or less than 0", maxDatacenterId)
 	}
 
-	// Initialize DistributeId instance.
-	id := &DistributeId{
-		epoch:            epoch,
-		workerIdBits:     workerIdBits,
-		datacenterIdBits: datacenterIdBits,
-		sequenceBits:     sequenceBits,
-		workerId:         workerId,
-		datacenterId:     datacenterId,
-		m:                &sync.Mutex{},
+	// Initialize the fields derived from the layout.
+	timeUnit := layout.TimeUnit.Milliseconds()
+	id.epoch = layout.Epoch / timeUnit
+	id.timeUnit = timeUnit
+	id.timestampBits = layout.TimestampBits
+	id.workerIdBits = workerIdBits
+	id.datacenterIdBits = datacenterIdBits
+	id.sequenceBits = sequenceBits
+
+	if id.rollbackPolicy == RollbackExtension && id.extensionBits == 0 {
+		return nil, fmt.Errorf("rollback policy %s requires extension bits", id.rollbackPolicy)
+	}
+
+	// The extension bits come out of the timestamp if it takes the remaining bits, otherwise they must fit.
+	nonTimestampBits := sequenceBits + workerIdBits + datacenterIdBits + id.extensionBits
+	if id.timestampBits == 0 {
+		id.timestampBits = 63 - nonTimestampBits
+	}
+	if id.timestampBits < 1 || id.timestampBits+nonTimestampBits > 63 {
+		return nil, fmt.Errorf("bit widths add up to %d, more than the 63 bits of a positive int64", max(id.timestampBits, 1)+nonTimestampBits)
 	}
 
 	// Calculate bit shifts for combining components into a 64-bit ID.
-	id.workerIdShift = sequenceBits
-	id.datacenterIdShift = sequenceBits + workerIdBits
-	id.timestampLeftShift = sequenceBits + workerIdBits + datacenterIdBits
+	// By default the sequence takes the lowest bits, followed by the worker ID and the datacenter ID.
+	// The optional extension bits sit between the highest of them and the timestamp.
+	if layout.SequenceAboveNode {
+		id.workerIdShift = 0
+		id.datacenterIdShift = workerIdBits
+		id.sequenceShift = workerIdBits + datacenterIdBits
+	} else {
+		id.sequenceShift = 0
+		id.workerIdShift = sequenceBits
+		id.datacenterIdShift = sequenceBits + workerIdBits
+	}
+	id.extensionShift = sequenceBits + workerIdBits + datacenterIdBits
+	id.timestampLeftShift = sequenceBits + workerIdBits + datacenterIdBits + id.extensionBits
+	if id.extensionBits > 0 {
+		id.extensionHigh = make([]int64, 1<<id.extensionBits)
+	}
 
 	// Calculate the sequence mask.
 	id.sequenceMask = -1 ^ (-1 << sequenceBits)
 
+	// Continue from the persisted high-water mark so IDs issued before a restart are never reissued.
+	if id.stateStore != nil {
+		if err := id.restoreState(); err != nil {
+			return nil, err
+		}
+	}
+
 	return id, nil
 }
 
 // NextId generates a unique 64-bit Snowflake ID.
 // It is thread-safe and handles clock rollback and sequence overflow.
+// How a clock rollback is handled depends on the configured RollbackPolicy.
 func (d *DistributeId) NextId() (int64, error) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
+	if err := d.checkLease(); err != nil {
+		return 0, err
+	}
+	return d.nextId()
+}
+
+// NextIds generates n unique Snowflake IDs under a single lock acquisition.
+// IDs within the same millisecond form a contiguous range, so a batch costs little more than
+// one call to NextId per millisecond it spans. This is intended for bulk inserts that need many IDs at once.
+// The IDs are returned in ascending order. If an error occurs, no IDs are returned.
+func (d *DistributeId) NextIds(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("number of IDs must be positive, got %d", n)
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if err := d.checkLease(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		id, err := d.nextId()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+
+		// Reserve the rest of the sequence of the current time unit in one go.
+		count := min(int64(n-len(ids)), d.sequenceMask-d.sequence)
+		for i := int64(1); i <= count; i++ {
+			ids = append(ids, id+i<<d.sequenceShift)
+		}
+		d.sequence += count
+		d.issued.Add(count)
+	}
+	return ids, nil
+}
+
+// checkLease refuses to generate IDs once the worker ID lease is lost, as another instance may now own the worker ID.
+// The caller must hold the lock.
+func (d *DistributeId) checkLease() error {
+	if d.lease == nil {
+		return nil
+	}
+	return d.lease.CheckLease()
+}
+
+// checkTimestamp returns an error if elapsed time units since the epoch don't fit into the timestamp bits.
+func (d *DistributeId) checkTimestamp(elapsed int64) error {
+	if elapsed >= 1<<d.timestampBits {
+		return fmt.Errorf("timestamp %d is outside the %d timestamp bits of the layout", elapsed, d.timestampBits)
+	}
+	return nil
+}
+
+// nextId generates the next ID. The caller must hold the lock.
+func (d *DistributeId) nextId() (int64, error) {
 	timestamp := d.timeGen()
 
 	// Handle clock rollback: if current timestamp is less than lastTimestamp, it means clock moved backwards.
-	// This is a critical error as it can lead to duplicate IDs.
+	// Generating IDs naively in this state can lead to duplicate IDs, so the rollback policy decides what to do.
 	if timestamp < d.lastTimestamp {
-		return 0, fmt.Errorf("clock moved backwards. Refusing to generate ID for %d milliseconds", d.lastTimestamp-timestamp)
+		var err error
+		if timestamp, err = d.handleRollback(timestamp); err != nil {
+			return 0, err
+		}
+	} else {
+		d.rollingBack = false
 	}
 
 	// If the current timestamp is the same as the last timestamp, increment the sequence.
 	if d.lastTimestamp == timestamp {
 		d.sequence = (d.sequence + 1) & d.sequenceMask
-		// If sequence overflows (reaches sequenceMask + 1), wait for the next millisecond.
+		// If sequence overflows (reaches sequenceMask + 1), move on to the next millisecond.
 		if d.sequence == 0 {
-			timestamp = d.tilNextMillis(d.lastTimestamp)
+			d.exhaustionWaits.Add(1)
+			var err error
+			if timestamp, err = d.nextTimestamp(d.lastTimestamp); err != nil {
+				return 0, err
+			}
 		}
 	} else {
 		// If the timestamp has changed, reset the sequence to 0.
 		d.sequence = 0
 	}
 
+	// The timestamp must fit into its bits, otherwise it would overflow into the sign bit.
+	if err := d.checkTimestamp(timestamp - d.epoch); err != nil {
+		return 0, err
+	}
+	if err := d.reserve(timestamp); err != nil {
+		return 0, err
+	}
+
 	// Update lastTimestamp for the next ID generation.
 	d.lastTimestamp = timestamp
+	if d.extensionBits > 0 {
+		d.extensionHigh[d.extension] = timestamp
+	}
 
 	// Combine all components into a 64-bit ID using bitwise operations:
-	// (timestamp - epoch) << timestampLeftShift | (datacenterId << datacenterIdShift) | (workerId << workerIdShift) | sequence
+	// (timestamp - epoch) << timestampLeftShift | (extension << extensionShift) | (datacenterId << datacenterIdShift) | (workerId << workerIdShift) | (sequence << sequenceShift)
 	id := ((timestamp - d.epoch) << d.timestampLeftShift) |
+		(d.extension << d.extensionShift) |
 		(d.datacenterId << d.datacenterIdShift) |
 		(d.workerId << d.workerIdShift) |
-		d.sequence
+		(d.sequence << d.sequenceShift)
+	d.issued.Add(1)
 
 	return id, nil
 }
@@ -152,24 +361,56 @@ func (d *DistributeId) NextStringId() (string, error) {
 	return strconv.FormatInt(id, 10), nil
 }
 
-// timeGen returns the current timestamp in milliseconds since epoch.
-// This function can be mocked for testing purposes.
-var timeGenFunc = func() int64 {
-	return time.Now().UnixMilli()
+// IdParts holds the components decoded from a Snowflake ID.
+type IdParts struct {
+	Id           int64     // The original ID.
+	Time         time.Time // The time the ID was generated, with the precision of the layout's time unit.
+	Timestamp    int64     // The raw timestamp component (time units since epoch of the generator).
+	Extension    int64     // The clock rollback extension component (0 unless extension bits are configured).
+	DatacenterId int64     // The datacenter ID component.
+	WorkerId     int64     // The worker ID component.
+	Sequence     int64     // The sequence number within the time unit.
 }
 
-// timeGen returns the current timestamp in milliseconds since epoch.
-// This function can be mocked for testing purposes.
+// Parse decomposes a Snowflake ID generated by this instance (or one with the same layout)
+// back into its timestamp, datacenter ID, worker ID and sequence.
+func (d *DistributeId) Parse(id int64) IdParts {
+	timestamp := id >> d.timestampLeftShift
+	return IdParts{
+		Id:           id,
+		Time:         time.UnixMilli((timestamp + d.epoch) * d.timeUnit),
+		Timestamp:    timestamp,
+		Extension:    (id >> d.extensionShift) & (-1 ^ (-1 << d.extensionBits)),
+		DatacenterId: (id >> d.datacenterIdShift) & (-1 ^ (-1 << d.datacenterIdBits)),
+		WorkerId:     (id >> d.workerIdShift) & (-1 ^ (-1 << d.workerIdBits)),
+		Sequence:     (id >> d.sequenceShift) & d.sequenceMask,
+	}
+}
+
+// Stats returns the counters of the generator.
+func (d *DistributeId) Stats() Stats {
+	return Stats{
+		Issued:          d.issued.Load(),
+		ExhaustionWaits: d.exhaustionWaits.Load(),
+		Rollbacks:       d.rollbacks.Load(),
+	}
+}
+
+// timeGen returns the current timestamp in time units of the layout, read from the clock of the generator.
 func (d *DistributeId) timeGen() int64 {
-	return timeGenFunc()
+	return d.clock.Now().UnixMilli() / d.timeUnit
 }
 
-// tilNextMillis blocks until the next millisecond is reached.
-// This is used when the sequence number for the current millisecond has been exhausted.
+// tilNextMillis blocks until the next time unit is reached.
+// This is used when the sequence number for the current time unit has been exhausted.
+// Time units longer than a millisecond are waited out with short sleeps instead of spinning.
 func (d *DistributeId) tilNextMillis(lastTimestamp int64) int64 {
-	timestamp := timeGenFunc()
+	timestamp := d.timeGen()
 	for timestamp <= lastTimestamp {
-		timestamp = timeGenFunc()
+		if d.timeUnit > 1 {
+			time.Sleep(time.Millisecond)
+		}
+		timestamp = d.timeGen()
 	}
 	return timestamp
 }
@@ -185,3 +426,9 @@ func NextId() (int64, error) {
 func NextStringId() (string, error) {
 	return Default.NextStringId()
 }
+
+// Parse decomposes a Snowflake ID generated with the default epoch and bit lengths.
+// This is a convenience function for inspecting IDs produced by NextId or NewWithDefault instances.
+func Parse(id int64) IdParts {
+	return Default.Parse(id)
+}
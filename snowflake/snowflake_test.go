@@ -1,6 +1,7 @@
 package snowflake
 
 import (
+	"fmt"
 	"strconv"
 	"sync"
 	"testing"
@@ -9,9 +10,6 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// Mockable timeGen for testing clock rollback and sequence overflow
-// We will modify the package-level timeGenFunc for testing purposes.
-
 func TestNew(t *testing.T) {
 	// Test valid creation
 	id, err := New(DefaultEpoch, 5, 5, 12, 0, 0)
@@ -90,22 +88,15 @@ func TestDistributeId_NextId_Concurrency(t *testing.T) {
 
 func TestDistributeId_NextId_ClockRollback(t *testing.T) {
 	// Test clock rollback
-	originalTimeGenFunc := timeGenFunc
-	defer func() {
-		timeGenFunc = originalTimeGenFunc
-	}()
-
-	id, err := NewWithDefault(0, 0)
+	now := mockClock(1000)
+	id, err := NewWithDefault(0, 0, WithClock(now))
 	assert.NoError(t, err)
 
-	mockTime := int64(1000)
-	timeGenFunc = func() int64 { return mockTime }
-
 	_, err = id.NextId()
 	assert.NoError(t, err)
 
 	// Simulate clock moving backwards
-	mockTime = 999
+	now.Store(999)
 	_, err = id.NextId()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "clock moved backwards")
@@ -113,37 +104,110 @@ func TestDistributeId_NextId_ClockRollback(t *testing.T) {
 
 func TestDistributeId_NextId_SequenceOverflow(t *testing.T) {
 	// Test sequence overflow
-	originalTimeGenFunc := timeGenFunc
-	defer func() {
-		timeGenFunc = originalTimeGenFunc
-	}()
-
-	id, err := NewWithDefault(0, 0)
+	now := mockClock(1000)
+	id, err := NewWithDefault(0, 0, WithClock(now))
 	assert.NoError(t, err)
 
-	mockTime := int64(1000)
-	timeGenFunc = func() int64 { return mockTime }
-
-	id.lastTimestamp = 1000
+	id.lastTimestamp = mockBase + 1000
 	id.sequence = id.sequenceMask // Max sequence for current millisecond
 
 	// Next ID should trigger sequence overflow and wait for next millisecond
-	mockTime = 1000 // Keep time same to trigger overflow
 	go func() {
 		// Advance time slightly after a short delay to unblock tilNextMillis
 		time.Sleep(10 * time.Millisecond)
-		mockTime = 1001
+		now.Store(1001)
 	}()
 
 	newID, err := id.NextId()
 	assert.NoError(t, err)
 	// Verify that the timestamp part of the new ID is from the next millisecond
 	// (timestamp - epoch) << timestampLeftShift
-	expectedTimestampPart := (1001 - id.epoch) << id.timestampLeftShift
+	expectedTimestampPart := (mockBase + 1001 - id.epoch) << id.timestampLeftShift
 	actualTimestampPart := newID & (^((1 << id.timestampLeftShift) - 1))
 	assert.Equal(t, expectedTimestampPart, actualTimestampPart, "Timestamp part of ID mismatch after overflow")
 }
 
+func TestNewGenerator(t *testing.T) {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := mockClock(1000)
+	second := mockClock(5000)
+
+	// Each generator reads its own clock.
+	a, err := NewGenerator(WithEpoch(epoch), WithWorkerId(3), WithDatacenterId(4), WithClock(first))
+	assert.NoError(t, err)
+	b, err := NewGenerator(WithEpoch(epoch), WithWorkerId(3), WithDatacenterId(4), WithClock(second))
+	assert.NoError(t, err)
+
+	idA, err := a.NextId()
+	assert.NoError(t, err)
+	idB, err := b.NextId()
+	assert.NoError(t, err)
+	partsA, partsB := a.Parse(idA), b.Parse(idB)
+	assert.Equal(t, int64(mockBase+1000), partsA.Time.UnixMilli())
+	assert.Equal(t, int64(mockBase+5000), partsB.Time.UnixMilli())
+	assert.Equal(t, mockBase+1000-epoch.UnixMilli(), partsA.Timestamp)
+	assert.Equal(t, int64(3), partsA.WorkerId)
+	assert.Equal(t, int64(4), partsA.DatacenterId)
+
+	// The positional constructor and the options produce the same IDs.
+	legacy, err := New(epoch.UnixMilli(), 5, 5, 12, 3, 4, WithClock(first))
+	assert.NoError(t, err)
+	idLegacy, err := legacy.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, idA, idLegacy)
+
+	// The options are validated.
+	_, err = NewGenerator(WithWorkerId(32))
+	assert.Error(t, err)
+	_, err = NewGenerator(WithClock(nil))
+	assert.Error(t, err)
+	_, err = NewGenerator(WithLayout(Layout{}))
+	assert.Error(t, err)
+	_, err = NewAtomicGenerator(WithRollbackPolicy(RollbackWait, time.Second))
+	assert.Error(t, err)
+}
+
+func TestDistributeId_Stats(t *testing.T) {
+	now := mockClock(1000)
+	layout := DefaultLayout
+	layout.SequenceBits = 1
+	id, err := NewGenerator(WithLayout(layout), WithClock(now))
+	assert.NoError(t, err)
+
+	_, err = id.NextIds(2)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		now.Store(1001)
+	}()
+	_, err = id.NextId()
+	assert.NoError(t, err)
+	now.Store(900)
+	_, err = id.NextId()
+	assert.Error(t, err)
+	assert.Equal(t, Stats{Issued: 3, ExhaustionWaits: 1, Rollbacks: 1}, id.Stats())
+
+	atomicID, err := NewAtomicGenerator(WithLayout(layout), WithClock(now))
+	assert.NoError(t, err)
+	now.Store(1000)
+	for i := 0; i < 2; i++ {
+		_, err = atomicID.NextId()
+		assert.NoError(t, err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		now.Store(1001)
+	}()
+	_, err = atomicID.NextId()
+	assert.NoError(t, err)
+	now.Store(900)
+	_, err = atomicID.NextId()
+	assert.Error(t, err)
+	_, err = atomicID.NextId()
+	assert.Error(t, err)
+	assert.Equal(t, Stats{Issued: 3, ExhaustionWaits: 1, Rollbacks: 1}, atomicID.Stats())
+}
+
 func TestDistributeId_NextStringId(t *testing.T) {
 	// Test NextStringId
 	id, err := NewWithDefault(0, 0)
@@ -172,3 +236,156 @@ func TestNextStringId(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, strID)
 }
+
+func TestDistributeId_Parse(t *testing.T) {
+	// Test decomposing IDs from a custom configured instance
+	epoch := int64(1600000000000)
+	id, err := New(epoch, 6, 4, 10, 45, 9)
+	assert.NoError(t, err)
+
+	before := time.Now().UnixMilli()
+	newID, err := id.NextId()
+	assert.NoError(t, err)
+	after := time.Now().UnixMilli()
+
+	parts := id.Parse(newID)
+	assert.Equal(t, newID, parts.Id)
+	assert.Equal(t, int64(45), parts.WorkerId)
+	assert.Equal(t, int64(9), parts.DatacenterId)
+	assert.Equal(t, id.sequence, parts.Sequence)
+	assert.Equal(t, parts.Timestamp+epoch, parts.Time.UnixMilli())
+	assert.GreaterOrEqual(t, parts.Time.UnixMilli(), before)
+	assert.LessOrEqual(t, parts.Time.UnixMilli(), after)
+
+	// Test that a hand-built ID is decoded into the expected components
+	handBuilt := (int64(123456) << id.timestampLeftShift) | (3 << id.datacenterIdShift) | (17 << id.workerIdShift) | 1000
+	parts = id.Parse(handBuilt)
+	assert.Equal(t, int64(123456), parts.Timestamp)
+	assert.Equal(t, int64(3), parts.DatacenterId)
+	assert.Equal(t, int64(17), parts.WorkerId)
+	assert.Equal(t, int64(1000), parts.Sequence)
+	assert.True(t, parts.Time.Equal(time.UnixMilli(epoch+123456)))
+}
+
+func TestParse(t *testing.T) {
+	// Test global Parse against an ID from a default-layout instance
+	id, err := NewWithDefault(7, 12)
+	assert.NoError(t, err)
+
+	newID, err := id.NextId()
+	assert.NoError(t, err)
+
+	parts := Parse(newID)
+	assert.Equal(t, int64(7), parts.WorkerId)
+	assert.Equal(t, int64(12), parts.DatacenterId)
+	assert.WithinDuration(t, time.Now(), parts.Time, time.Second)
+}
+
+func TestDistributeId_NextIds(t *testing.T) {
+	id, err := NewWithDefault(1, 1)
+	assert.NoError(t, err)
+
+	_, err = id.NextIds(0)
+	assert.Error(t, err)
+
+	// A batch larger than the sequence space spans several milliseconds.
+	ids, err := id.NextIds(10000)
+	assert.NoError(t, err)
+	assert.Len(t, ids, 10000)
+	for i := 1; i < len(ids); i++ {
+		assert.Greater(t, ids[i], ids[i-1], "IDs must be strictly ascending")
+	}
+
+	// IDs after the batch continue where it ended.
+	next, err := id.NextId()
+	assert.NoError(t, err)
+	assert.Greater(t, next, ids[len(ids)-1])
+}
+
+func TestDistributeId_NextIds_Contiguous(t *testing.T) {
+	now := mockClock(1000)
+	id, err := NewWithDefault(0, 0, WithClock(now))
+	assert.NoError(t, err)
+
+	// Within one millisecond the batch is a contiguous range.
+	ids, err := id.NextIds(100)
+	assert.NoError(t, err)
+	for i := range ids {
+		assert.Equal(t, ids[0]+int64(i), ids[i])
+	}
+	assert.Equal(t, int64(99), id.sequence)
+
+	// Errors such as a clock rollback fail the whole batch.
+	now.Store(999)
+	ids, err = id.NextIds(10)
+	assert.Error(t, err)
+	assert.Nil(t, ids)
+}
+
+func TestDistributeId_NextIds_Concurrency(t *testing.T) {
+	id, err := NewWithDefault(0, 0)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	uniqueIDs := make(map[int64]struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				ids, err := id.NextIds(500)
+				assert.NoError(t, err)
+				mu.Lock()
+				for _, newID := range ids {
+					_, exists := uniqueIDs[newID]
+					assert.False(t, exists, "Duplicate ID generated in concurrent test: %d", newID)
+					uniqueIDs[newID] = struct{}{}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 8*5*500, len(uniqueIDs))
+}
+
+// BenchmarkGenerators_Parallel compares the mutex-based generator, batch generation and the
+// lock-free generator under heavy parallelism. Run with -cpu to vary the number of goroutines.
+// With the default 12 sequence bits all variants are capped at 4096 IDs per millisecond,
+// so the 20 bit layout is included to measure the cost of the generators themselves.
+func BenchmarkGenerators_Parallel(b *testing.B) {
+	for _, sequenceBits := range []int{12, 20} {
+		b.Run(fmt.Sprintf("Seq%d/Mutex", sequenceBits), func(b *testing.B) {
+			id, _ := New(DefaultEpoch, 1, 1, sequenceBits, 0, 0)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, _ = id.NextId()
+				}
+			})
+		})
+
+		b.Run(fmt.Sprintf("Seq%d/Batch100", sequenceBits), func(b *testing.B) {
+			id, _ := New(DefaultEpoch, 1, 1, sequenceBits, 0, 0)
+			b.RunParallel(func(pb *testing.PB) {
+				// Every iteration yields one ID; a new batch is fetched when the current one is used up.
+				var ids []int64
+				for pb.Next() {
+					if len(ids) == 0 {
+						ids, _ = id.NextIds(100)
+					}
+					ids = ids[1:]
+				}
+			})
+		})
+
+		b.Run(fmt.Sprintf("Seq%d/Atomic", sequenceBits), func(b *testing.B) {
+			id, _ := NewAtomic(DefaultEpoch, 1, 1, sequenceBits, 0, 0)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, _ = id.NextId()
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStateReserve is the default time the persisted high-water mark is reserved ahead of the clock.
+var DefaultStateReserve = 3 * time.Second
+
+// StateStore persists the high-water mark of a generator, the Unix time in milliseconds up to which
+// IDs may have been issued. It lets a restarted generator avoid reissuing IDs when the host comes back
+// with an earlier wall clock. Each generator needs its own store.
+type StateStore interface {
+	// LoadHighWaterMark returns the persisted mark, or 0 if none has been saved yet.
+	LoadHighWaterMark() (int64, error)
+	// SaveHighWaterMark durably stores the mark before any ID up to it is issued.
+	SaveHighWaterMark(mark int64) error
+}
+
+// WithStateStore returns an Option that persists the high-water mark of the generator in store.
+// The mark is reserved reserve ahead of the clock and saved again whenever the clock passes it,
+// so the store is written about once per reserve interval. A reserve of 0 uses DefaultStateReserve.
+//
+// On startup the generator continues from the persisted mark. If the clock is still behind it,
+// this is handled like a clock rollback: with RollbackFail NextId refuses to generate IDs until
+// the clock has passed the mark, with RollbackWait it waits for it within the tolerance.
+// A longer reserve means fewer writes but a longer wait after a restart.
+func WithStateStore(store StateStore, reserve time.Duration) Option {
+	return func(d *DistributeId) error {
+		if store == nil {
+			return errors.New("state store is nil")
+		}
+		if reserve < 0 {
+			return fmt.Errorf("state reserve can't be negative: %s", reserve)
+		}
+		if reserve == 0 {
+			reserve = DefaultStateReserve
+		}
+		d.stateStore = store
+		d.stateReserve = reserve
+		return nil
+	}
+}
+
+// restoreState continues from the persisted high-water mark, as if IDs had been generated up to it.
+func (d *DistributeId) restoreState() error {
+	mark, err := d.stateStore.LoadHighWaterMark()
+	if err != nil {
+		return fmt.Errorf("failed to load snowflake high-water mark: %w", err)
+	}
+	timestamp := mark / d.timeUnit
+	d.lastTimestamp = timestamp
+	d.sequence = d.sequenceMask
+	d.reservedUntil = timestamp
+	for e := range d.extensionHigh {
+		d.extensionHigh[e] = timestamp
+	}
+	return nil
+}
+
+// reserve persists a new high-water mark ahead of timestamp if timestamp has passed the reserved one.
+// The caller must hold the lock.
+func (d *DistributeId) reserve(timestamp int64) error {
+	if d.stateStore == nil || timestamp <= d.reservedUntil {
+		return nil
+	}
+	ahead := max(int64(d.stateReserve/(time.Duration(d.timeUnit)*time.Millisecond)), 1)
+	if err := d.stateStore.SaveHighWaterMark((timestamp + ahead) * d.timeUnit); err != nil {
+		return fmt.Errorf("failed to persist snowflake high-water mark: %w", err)
+	}
+	d.reservedUntil = timestamp + ahead
+	return nil
+}
+
+// FileStateStore is a StateStore that keeps the high-water mark in a file as a decimal number.
+// The file is replaced atomically, so a crash never leaves a partially written mark behind.
+type FileStateStore struct {
+	path string
+}
+
+// NewFileStateStore creates a FileStateStore that keeps the high-water mark in the file at path.
+// The directory must exist; the file is created on the first save.
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// LoadHighWaterMark reads the mark from the file. It returns 0 if the file doesn't exist.
+func (s *FileStateStore) LoadHighWaterMark() (int64, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	mark, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid high-water mark in %s: %w", s.path, err)
+	}
+	return mark, nil
+}
+
+// SaveHighWaterMark writes the mark to a temporary file, syncs it and renames it over the file.
+func (s *FileStateStore) SaveHighWaterMark(mark int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.WriteString(strconv.FormatInt(mark, 10) + "\n"); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
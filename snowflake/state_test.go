@@ -0,0 +1,132 @@
+package snowflake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStateStore is an in-memory StateStore that can be made to fail.
+type memoryStateStore struct {
+	mark  int64
+	saves int
+	err   error
+}
+
+func (s *memoryStateStore) LoadHighWaterMark() (int64, error) {
+	return s.mark, s.err
+}
+
+func (s *memoryStateStore) SaveHighWaterMark(mark int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mark = mark
+	s.saves++
+	return nil
+}
+
+func TestFileStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snowflake.state")
+	store := NewFileStateStore(path)
+
+	// A missing file means no mark has been saved yet.
+	mark, err := store.LoadHighWaterMark()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), mark)
+
+	assert.NoError(t, store.SaveHighWaterMark(mockBase))
+	assert.NoError(t, store.SaveHighWaterMark(mockBase+3000))
+	mark, err = NewFileStateStore(path).LoadHighWaterMark()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase+3000), mark)
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.NoError(t, os.WriteFile(path, []byte("garbage"), 0600))
+	_, err = store.LoadHighWaterMark()
+	assert.Error(t, err)
+
+	assert.Error(t, NewFileStateStore(filepath.Join(path, "missing", "state")).SaveHighWaterMark(1))
+}
+
+func TestWithStateStore_ReservesAhead(t *testing.T) {
+	now := mockClock(1000)
+	store := &memoryStateStore{}
+	id, err := NewWithDefault(0, 0, WithClock(now), WithStateStore(store, 100*time.Millisecond))
+	assert.NoError(t, err)
+
+	_, err = id.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase+1100), store.mark)
+	assert.Equal(t, 1, store.saves)
+
+	// The store is not written again until the clock passes the reserved mark.
+	for ; now.Load() <= 1100; now.Add(10) {
+		_, err = id.NextId()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, store.saves)
+	_, err = id.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, store.saves)
+	assert.Equal(t, int64(mockBase+1210), store.mark)
+
+	// IDs are refused if the mark can't be persisted.
+	now.Store(2000)
+	store.err = errors.New("disk full")
+	_, err = id.NextId()
+	assert.ErrorContains(t, err, "disk full")
+}
+
+func TestWithStateStore_Restart(t *testing.T) {
+	now := mockClock(1000)
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "snowflake.state"))
+	id, err := NewWithDefault(0, 0, WithClock(now), WithStateStore(store, 50*time.Millisecond))
+	assert.NoError(t, err)
+	issued, err := id.NextId()
+	assert.NoError(t, err)
+
+	// After a restart with an earlier clock, IDs are refused until the clock has passed the mark.
+	now.Store(900)
+	restarted, err := NewWithDefault(0, 0, WithClock(now), WithStateStore(store, 50*time.Millisecond))
+	assert.NoError(t, err)
+	_, err = restarted.NextId()
+	assert.True(t, errors.Is(err, ErrClockMovedBackwards))
+
+	now.Store(1051)
+	next, err := restarted.NextId()
+	assert.NoError(t, err)
+	assert.Greater(t, next, issued)
+
+	// With RollbackWait the generator waits for the clock to pass the mark instead.
+	now.Store(1060)
+	_, err = restarted.NextId()
+	assert.NoError(t, err)
+	now.Store(1000)
+	waiting, err := NewWithDefault(0, 0, WithClock(now), WithStateStore(store, 50*time.Millisecond), WithRollbackPolicy(RollbackWait, time.Second))
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		now.Store(1111)
+	}()
+	next, err = waiting.NextId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase+1111), waiting.Parse(next).Time.UnixMilli())
+}
+
+func TestWithStateStore_Invalid(t *testing.T) {
+	_, err := NewWithDefault(0, 0, WithStateStore(nil, 0))
+	assert.Error(t, err)
+	_, err = NewWithDefault(0, 0, WithStateStore(&memoryStateStore{}, -time.Second))
+	assert.Error(t, err)
+	_, err = NewWithDefault(0, 0, WithStateStore(&memoryStateStore{err: errors.New("unreachable")}, 0))
+	assert.ErrorContains(t, err, "unreachable")
+}
@@ -0,0 +1,192 @@
+package snowflake
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier: a 48-bit Unix timestamp
+// in milliseconds followed by 80 random bits, written as 26 Crockford base32 characters.
+// It marshals to JSON and text in that form and is stored as a string in databases.
+type ULID [16]byte
+
+// String returns the 26-character Crockford base32 form of the ULID.
+func (u ULID) String() string {
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	// Each character holds 5 bits of the 130-bit value whose 2 leading bits are zero.
+	for i := 25; i >= 0; i-- {
+		buf[i] = Base32.alphabet[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// Time returns the time encoded in the ULID, with millisecond precision.
+func (u ULID) Time() time.Time {
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u[:8]) >> 16))
+}
+
+// ParseULID parses a ULID. Decoding follows the Crockford rules: it is case-insensitive
+// and accepts O as 0 and I and L as 1.
+func ParseULID(s string) (ULID, error) {
+	if len(s) != 26 {
+		return ULID{}, fmt.Errorf("invalid ULID %q: must have 26 characters", s)
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		digit := Base32.decodeMap[s[i]]
+		if digit < 0 {
+			return ULID{}, fmt.Errorf("invalid character %q in ULID %q", s[i], s)
+		}
+		if i == 0 && digit > 7 {
+			return ULID{}, fmt.Errorf("ULID %q overflows 128 bits", s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(digit)
+	}
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It is also used by encoding/json.
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It is also used by encoding/json.
+func (u *ULID) UnmarshalText(text []byte) error {
+	v, err := ParseULID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// Scan implements sql.Scanner. It accepts string column values and []byte values
+// holding either the text form or the 16 raw bytes. A NULL column value sets the zero ULID.
+func (u *ULID) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*u = ULID{}
+	case []byte:
+		if len(v) == len(u) {
+			copy(u[:], v)
+			return nil
+		}
+		return u.UnmarshalText(v)
+	case string:
+		return u.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("can't scan %T into ULID", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. The ULID is stored in its 26-character string form.
+func (u ULID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface.
+func (ULID) GormDataType() string {
+	return string(schema.String)
+}
+
+// GormDBDataType implements migrator.GormDataTypeInterface and returns the column type for each dialect.
+func (ULID) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "text"
+	}
+	return "char(26)"
+}
+
+// ULIDGenerator generates ULIDs. No worker ID coordination is needed.
+// ULIDs generated by the same generator are strictly increasing: within a millisecond, the random
+// part of the previous ULID is incremented by one, as in the monotonic mode of the ULID specification.
+// Instead of failing when the random part overflows, or when the clock moves backwards,
+// the timestamp is advanced past the last one.
+type ULIDGenerator struct {
+	m             sync.Mutex
+	clock         Clock    // 时间来源
+	lastTimestamp int64    // 上次生成ULID的时间截 (毫秒)
+	entropy       [10]byte // 上次生成ULID的80位随机部分
+}
+
+// NewULIDGenerator creates a new ULIDGenerator that reads the time from SystemClock.
+func NewULIDGenerator() *ULIDGenerator {
+	return NewULIDGeneratorWithClock(SystemClock)
+}
+
+// NewULIDGeneratorWithClock creates a new ULIDGenerator that reads the time from clock.
+func NewULIDGeneratorWithClock(clock Clock) *ULIDGenerator {
+	return &ULIDGenerator{clock: clock}
+}
+
+// NextULID generates a new ULID.
+func (g *ULIDGenerator) NextULID() (ULID, error) {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	timestamp := g.clock.Now().UnixMilli()
+	fresh := true
+	switch {
+	case timestamp > g.lastTimestamp:
+		// A new millisecond starts with new random bits.
+	case g.increment():
+		// The random part overflowed: move on to the next millisecond.
+		timestamp = g.lastTimestamp + 1
+	default:
+		timestamp, fresh = g.lastTimestamp, false
+	}
+	if fresh {
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			return ULID{}, fmt.Errorf("failed to read random bits: %w", err)
+		}
+	}
+	g.lastTimestamp = timestamp
+
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], uint64(timestamp)<<16)
+	copy(u[6:], g.entropy[:])
+	return u, nil
+}
+
+// increment adds one to the random part and reports whether it overflowed.
+func (g *ULIDGenerator) increment() bool {
+	for i := len(g.entropy) - 1; i >= 0; i-- {
+		g.entropy[i]++
+		if g.entropy[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NextStringId generates a new ULID and returns it in its 26-character form.
+func (g *ULIDGenerator) NextStringId() (string, error) {
+	u, err := g.NextULID()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// defaultULID is the generator used by NewULID.
+var defaultULID = NewULIDGenerator()
+
+// NewULID generates a new ULID using a shared generator.
+func NewULID() (ULID, error) {
+	return defaultULID.NextULID()
+}
@@ -0,0 +1,125 @@
+package snowflake
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Compile-time checks that ULID implements the expected interfaces.
+var (
+	_ encoding.TextMarshaler   = ULID{}
+	_ encoding.TextUnmarshaler = (*ULID)(nil)
+	_ sql.Scanner              = (*ULID)(nil)
+	_ driver.Valuer            = ULID{}
+)
+
+func TestParseULID(t *testing.T) {
+	// Timestamp example from the ULID specification.
+	u, err := ParseULID("01ARYZ6S41TSV4RRFFQ69G5FAV")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1469918176385), u.Time().UnixMilli())
+	assert.Equal(t, "01ARYZ6S41TSV4RRFFQ69G5FAV", u.String())
+
+	// Decoding is case-insensitive and accepts the Crockford aliases.
+	lower, err := ParseULID("0lARYZ6S4ltsv4rrffq69g5fav")
+	assert.NoError(t, err)
+	assert.Equal(t, u, lower)
+
+	max, err := ParseULID("7ZZZZZZZZZZZZZZZZZZZZZZZZZ")
+	assert.NoError(t, err)
+	assert.Equal(t, ULID{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, max)
+
+	for _, s := range []string{"", "01ARYZ6S41TSV4RRFFQ69G5FA", "8ZZZZZZZZZZZZZZZZZZZZZZZZZ", "01ARYZ6S41TSV4RRFFQ69G5FAU"} {
+		_, err = ParseULID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestULIDGenerator_NextULID(t *testing.T) {
+	now := mockClock(0)
+	g := NewULIDGeneratorWithClock(now)
+
+	first, err := g.NextULID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase), first.Time().UnixMilli())
+
+	// Within the same millisecond the random part is incremented.
+	second, err := g.NextULID()
+	assert.NoError(t, err)
+	assert.Equal(t, first.Time(), second.Time())
+	assert.Greater(t, second.String(), first.String())
+	assert.Equal(t, first[:15], second[:15], "only the last byte changes unless it carries")
+
+	// An overflowing random part advances the timestamp.
+	for i := range g.entropy {
+		g.entropy[i] = 0xFF
+	}
+	third, err := g.NextULID()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase+1), third.Time().UnixMilli())
+	assert.Greater(t, third.String(), second.String())
+
+	// A clock rollback doesn't break the ordering either.
+	now.Store(-1000)
+	fourth, err := g.NextULID()
+	assert.NoError(t, err)
+	assert.Greater(t, fourth.String(), third.String())
+
+	now.Store(10)
+	s, err := g.NextStringId()
+	assert.NoError(t, err)
+	parsed, err := ParseULID(s)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(mockBase+10), parsed.Time().UnixMilli())
+}
+
+func TestULID_Encoding(t *testing.T) {
+	type payload struct {
+		Id ULID `json:"id"`
+	}
+	u, err := NewULID()
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(payload{Id: u})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+u.String()+`"}`, string(data))
+	var p payload
+	assert.NoError(t, json.Unmarshal(data, &p))
+	assert.Equal(t, u, p.Id)
+
+	value, err := u.Value()
+	assert.NoError(t, err)
+	var scanned ULID
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan(u[:]))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan([]byte(strings.ToLower(u.String()))))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan(nil))
+	assert.Equal(t, ULID{}, scanned)
+	assert.Error(t, scanned.Scan(3.14))
+}
+
+func TestStringIDGenerator(t *testing.T) {
+	snowflakeGen, err := NewWithDefault(1, 1)
+	assert.NoError(t, err)
+	generators := map[string]StringIDGenerator{
+		"snowflake": snowflakeGen,
+		"uuidv7":    NewUUIDv7Generator(),
+		"ulid":      NewULIDGenerator(),
+	}
+	for name, g := range generators {
+		first, err := g.NextStringId()
+		assert.NoError(t, err, name)
+		second, err := g.NextStringId()
+		assert.NoError(t, err, name)
+		assert.NotEqual(t, first, second, name)
+	}
+}
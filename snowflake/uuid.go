@@ -0,0 +1,191 @@
+package snowflake
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// UUID is a 128-bit universally unique identifier as defined by RFC 9562.
+// It marshals to JSON and text in the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+// and is stored as a string in databases.
+type UUID [16]byte
+
+// String returns the canonical lower-case form of the UUID.
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Version returns the version of the UUID, 7 for UUIDs generated by UUIDv7Generator.
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+// Time returns the time encoded in the first 48 bits of a version 7 UUID, with millisecond precision.
+// The result is meaningless for other versions.
+func (u UUID) Time() time.Time {
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u[:8]) >> 16))
+}
+
+// ParseUUID parses a UUID in the canonical form, case-insensitively. The form without hyphens is accepted as well.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	var digits string
+	switch len(s) {
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, fmt.Errorf("invalid UUID %q", s)
+		}
+		digits = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	case 32:
+		digits = s
+	default:
+		return u, fmt.Errorf("invalid UUID %q: must have 32 or 36 characters", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("invalid UUID %q: %w", s, err)
+	}
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler. It is also used by encoding/json.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It is also used by encoding/json.
+func (u *UUID) UnmarshalText(text []byte) error {
+	v, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// Scan implements sql.Scanner. It accepts string column values and []byte values
+// holding either the text form or the 16 raw bytes. A NULL column value sets the zero UUID.
+func (u *UUID) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*u = UUID{}
+	case []byte:
+		if len(v) == len(u) {
+			copy(u[:], v)
+			return nil
+		}
+		return u.UnmarshalText(v)
+	case string:
+		return u.UnmarshalText([]byte(v))
+	default:
+		return fmt.Errorf("can't scan %T into UUID", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. The UUID is stored in its canonical string form.
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface.
+func (UUID) GormDataType() string {
+	return string(schema.String)
+}
+
+// GormDBDataType implements migrator.GormDataTypeInterface and returns the column type for each dialect.
+func (UUID) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "uuid"
+	case "sqlite":
+		return "text"
+	default:
+		return "char(36)"
+	}
+}
+
+// UUIDv7Generator generates version 7 UUIDs: a 48-bit Unix timestamp in milliseconds followed by
+// random bits. No worker ID coordination is needed.
+// UUIDs generated by the same generator are strictly increasing: within a millisecond, the 12 bits
+// after the timestamp are a counter starting at a random value (RFC 9562, section 6.2, method 1).
+// When the counter overflows, or the clock moves backwards, the timestamp is advanced past the last one.
+type UUIDv7Generator struct {
+	m             sync.Mutex
+	clock         Clock  // 时间来源
+	lastTimestamp int64  // 上次生成UUID的时间截 (毫秒)
+	counter       uint16 // 毫秒内的12位计数器
+}
+
+// NewUUIDv7Generator creates a new UUIDv7Generator that reads the time from SystemClock.
+func NewUUIDv7Generator() *UUIDv7Generator {
+	return NewUUIDv7GeneratorWithClock(SystemClock)
+}
+
+// NewUUIDv7GeneratorWithClock creates a new UUIDv7Generator that reads the time from clock.
+func NewUUIDv7GeneratorWithClock(clock Clock) *UUIDv7Generator {
+	return &UUIDv7Generator{clock: clock}
+}
+
+// NextUUID generates a new version 7 UUID.
+func (g *UUIDv7Generator) NextUUID() (UUID, error) {
+	var u UUID
+	// The random bits are read first: bytes 6 and 7 are replaced by the version and the counter.
+	if _, err := rand.Read(u[6:]); err != nil {
+		return UUID{}, fmt.Errorf("failed to read random bits: %w", err)
+	}
+
+	g.m.Lock()
+	timestamp := g.clock.Now().UnixMilli()
+	if timestamp > g.lastTimestamp {
+		// A new millisecond starts the counter at a random value below 2048, leaving room to count up.
+		g.lastTimestamp = timestamp
+		g.counter = uint16(u[6]&0x07)<<8 | uint16(u[7])
+	} else {
+		g.counter++
+		if g.counter > 0xFFF {
+			g.lastTimestamp++
+			g.counter = 0
+		}
+	}
+	timestamp, counter := g.lastTimestamp, g.counter
+	g.m.Unlock()
+
+	binary.BigEndian.PutUint64(u[:8], uint64(timestamp)<<16|0x7000|uint64(counter))
+	u[8] = u[8]&0x3F | 0x80 // RFC 9562 variant
+	return u, nil
+}
+
+// NextStringId generates a new version 7 UUID and returns it in its canonical form.
+func (g *UUIDv7Generator) NextStringId() (string, error) {
+	u, err := g.NextUUID()
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// defaultUUIDv7 is the generator used by NewUUIDv7.
+var defaultUUIDv7 = NewUUIDv7Generator()
+
+// NewUUIDv7 generates a new version 7 UUID using a shared generator.
+func NewUUIDv7() (UUID, error) {
+	return defaultUUIDv7.NextUUID()
+}
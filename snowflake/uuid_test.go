@@ -0,0 +1,139 @@
+package snowflake
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Compile-time checks that UUID implements the expected interfaces.
+var (
+	_ encoding.TextMarshaler   = UUID{}
+	_ encoding.TextUnmarshaler = (*UUID)(nil)
+	_ sql.Scanner              = (*UUID)(nil)
+	_ driver.Valuer            = UUID{}
+)
+
+func TestParseUUID(t *testing.T) {
+	// Example from RFC 9562, appendix A.6.
+	u, err := ParseUUID("017F22E2-79B0-7CC3-98C4-DC0C0C07398F")
+	assert.NoError(t, err)
+	assert.Equal(t, "017f22e2-79b0-7cc3-98c4-dc0c0c07398f", u.String())
+	assert.Equal(t, 7, u.Version())
+	assert.Equal(t, time.Date(2022, 2, 22, 19, 22, 22, 0, time.UTC), u.Time().UTC())
+
+	plain, err := ParseUUID("017f22e279b07cc398c4dc0c0c07398f")
+	assert.NoError(t, err)
+	assert.Equal(t, u, plain)
+
+	for _, s := range []string{"", "017f22e2-79b0-7cc3-98c4-dc0c0c07398", "017f22e2_79b0-7cc3-98c4-dc0c0c07398f", "017f22e2-79b0-7cc3-98c4-dc0c0c07398g"} {
+		_, err = ParseUUID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestUUIDv7Generator_NextUUID(t *testing.T) {
+	now := mockClock(0)
+	g := NewUUIDv7GeneratorWithClock(now)
+
+	first, err := g.NextUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, first.Version())
+	assert.Equal(t, byte(0x80), first[8]&0xC0, "RFC 9562 variant")
+	assert.Equal(t, int64(mockBase), first.Time().UnixMilli())
+
+	// Within the same millisecond the counter keeps UUIDs increasing, advancing the timestamp on overflow.
+	last := first
+	for i := 0; i < 5000; i++ {
+		next, err := g.NextUUID()
+		assert.NoError(t, err)
+		assert.Greater(t, next.String(), last.String())
+		last = next
+	}
+	assert.Greater(t, last.Time().UnixMilli(), int64(mockBase))
+
+	// A clock rollback doesn't break the ordering either.
+	now.Store(-1000)
+	next, err := g.NextUUID()
+	assert.NoError(t, err)
+	assert.Greater(t, next.String(), last.String())
+}
+
+func TestUUIDv7Generator_Concurrency(t *testing.T) {
+	g := NewUUIDv7Generator()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[UUID]struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				u, err := NewUUIDv7()
+				assert.NoError(t, err)
+				s, err := g.NextStringId()
+				assert.NoError(t, err)
+				parsed, err := ParseUUID(s)
+				assert.NoError(t, err)
+				mu.Lock()
+				seen[u] = struct{}{}
+				seen[parsed] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 16000)
+}
+
+func TestUUID_Encoding(t *testing.T) {
+	type payload struct {
+		Id UUID `json:"id"`
+	}
+	u, err := NewUUIDv7()
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(payload{Id: u})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+u.String()+`"}`, string(data))
+	var p payload
+	assert.NoError(t, json.Unmarshal(data, &p))
+	assert.Equal(t, u, p.Id)
+	assert.Error(t, json.Unmarshal([]byte(`{"id":"nope"}`), &p))
+
+	value, err := u.Value()
+	assert.NoError(t, err)
+	var scanned UUID
+	assert.NoError(t, scanned.Scan(value))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan(u[:]))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan([]byte(strings.ToUpper(u.String()))))
+	assert.Equal(t, u, scanned)
+	assert.NoError(t, scanned.Scan(nil))
+	assert.Equal(t, UUID{}, scanned)
+	assert.Error(t, scanned.Scan(42))
+}
+
+func TestUUID_Gorm(t *testing.T) {
+	type event struct {
+		Id   UUID `gorm:"primaryKey"`
+		Name string
+	}
+	db := newTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&event{}))
+
+	u, err := NewUUIDv7()
+	assert.NoError(t, err)
+	assert.NoError(t, db.Create(&event{Id: u, Name: "created"}).Error)
+	var loaded event
+	assert.NoError(t, db.Where("id = ?", u).Take(&loaded).Error)
+	assert.Equal(t, u, loaded.Id)
+}
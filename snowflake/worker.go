@@ -0,0 +1,199 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math/bits"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WorkerIDProvider assigns the worker ID and datacenter ID of a generator,
+// so deployments don't have to hand-pick unique values for every instance.
+type WorkerIDProvider interface {
+	// WorkerID returns the worker ID and datacenter ID to use.
+	// maxWorkerId and maxDatacenterId are the largest values allowed by the generator's bit lengths.
+	WorkerID(ctx context.Context, maxWorkerId int64, maxDatacenterId int64) (workerId int64, datacenterId int64, err error)
+}
+
+// LeaseChecker is implemented by providers whose assignment can be lost after it was handed out,
+// such as LeaseWorkerIDProvider. A generator configured with WithLease refuses to generate IDs
+// while CheckLease returns an error.
+type LeaseChecker interface {
+	// CheckLease returns nil if the assignment is still held. It is called for every ID and must be cheap.
+	CheckLease() error
+}
+
+// WorkerIDProviderFunc is an adapter to allow the use of ordinary functions as a WorkerIDProvider.
+type WorkerIDProviderFunc func(ctx context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error)
+
+// WorkerID calls f(ctx, maxWorkerId, maxDatacenterId).
+func (f WorkerIDProviderFunc) WorkerID(ctx context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+	return f(ctx, maxWorkerId, maxDatacenterId)
+}
+
+// WithLease returns an Option that makes the generator refuse to generate IDs once the lease
+// reported by checker is lost. NewWithProvider adds it automatically for providers implementing LeaseChecker.
+func WithLease(checker LeaseChecker) Option {
+	return func(d *DistributeId) error {
+		d.lease = checker
+		return nil
+	}
+}
+
+// NewWithProvider creates a new DistributeId instance with default epoch and bit lengths,
+// using the worker ID and datacenter ID assigned by provider.
+// If provider implements LeaseChecker, the generator stops generating IDs when the lease is lost.
+func NewWithProvider(ctx context.Context, provider WorkerIDProvider, opts ...Option) (*DistributeId, error) {
+	return NewGenerator(append([]Option{WithWorkerIDProvider(ctx, provider)}, opts...)...)
+}
+
+// WithWorkerIDProvider returns an Option that lets provider assign the worker ID and datacenter ID
+// within the bit lengths of the generator's layout when it is created. ctx is passed to the provider.
+// If provider implements LeaseChecker, the generator stops generating IDs when the lease is lost.
+func WithWorkerIDProvider(ctx context.Context, provider WorkerIDProvider) Option {
+	return func(d *DistributeId) error {
+		if provider == nil {
+			return errors.New("worker ID provider is nil")
+		}
+		d.provider = provider
+		d.providerCtx = ctx
+		return nil
+	}
+}
+
+// assignWorkerId asks the provider for the worker ID and datacenter ID.
+func (d *DistributeId) assignWorkerId(maxWorkerId int64, maxDatacenterId int64) error {
+	workerId, datacenterId, err := d.provider.WorkerID(d.providerCtx, maxWorkerId, maxDatacenterId)
+	if err != nil {
+		return fmt.Errorf("failed to assign worker ID: %w", err)
+	}
+	d.workerId, d.datacenterId = workerId, datacenterId
+	if checker, ok := d.provider.(LeaseChecker); ok && d.lease == nil {
+		d.lease = checker
+	}
+	return nil
+}
+
+// IPWorkerIDProvider returns a WorkerIDProvider that derives the IDs from the low bits of an IPv4 address.
+// The worker ID takes the lowest bits and the datacenter ID the bits above them, so with the default
+// bit lengths the IDs are unique within a /22 network. If ip is nil, the first non-loopback IPv4
+// address of the host is used.
+func IPWorkerIDProvider(ip net.IP) WorkerIDProvider {
+	return WorkerIDProviderFunc(func(_ context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+		addr := ip
+		if addr == nil {
+			var err error
+			if addr, err = hostIPv4(); err != nil {
+				return 0, 0, err
+			}
+		}
+		v4 := addr.To4()
+		if v4 == nil {
+			return 0, 0, fmt.Errorf("not an IPv4 address: %s", addr)
+		}
+		node := uint64(v4[0])<<24 | uint64(v4[1])<<16 | uint64(v4[2])<<8 | uint64(v4[3])
+		workerId, datacenterId := splitNodeId(node, maxWorkerId, maxDatacenterId)
+		return workerId, datacenterId, nil
+	})
+}
+
+// MACWorkerIDProvider returns a WorkerIDProvider that derives the IDs from a hash of a MAC address.
+// Hashing spreads the IDs evenly but, unlike IPWorkerIDProvider, does not guarantee uniqueness
+// within any address range. If mac is nil, the first non-loopback interface with a MAC address is used.
+func MACWorkerIDProvider(mac net.HardwareAddr) WorkerIDProvider {
+	return WorkerIDProviderFunc(func(_ context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+		addr := mac
+		if len(addr) == 0 {
+			var err error
+			if addr, err = hostMAC(); err != nil {
+				return 0, 0, err
+			}
+		}
+		h := fnv.New64a()
+		_, _ = h.Write(addr)
+		workerId, datacenterId := splitNodeId(h.Sum64(), maxWorkerId, maxDatacenterId)
+		return workerId, datacenterId, nil
+	})
+}
+
+// PodOrdinalWorkerIDProvider returns a WorkerIDProvider that derives the IDs from the ordinal suffix
+// of a Kubernetes StatefulSet pod name, such as "orders-3". The ordinal fills the worker ID first
+// and then the datacenter ID; an error is returned if it doesn't fit. If hostname is empty, the
+// host name of the machine (which is the pod name in Kubernetes) is used.
+func PodOrdinalWorkerIDProvider(hostname string) WorkerIDProvider {
+	return WorkerIDProviderFunc(func(_ context.Context, maxWorkerId int64, maxDatacenterId int64) (int64, int64, error) {
+		name := hostname
+		if name == "" {
+			var err error
+			if name, err = os.Hostname(); err != nil {
+				return 0, 0, fmt.Errorf("failed to get host name: %w", err)
+			}
+		}
+		idx := strings.LastIndex(name, "-")
+		if idx < 0 {
+			return 0, 0, fmt.Errorf("host name %q has no pod ordinal suffix", name)
+		}
+		ordinal, err := strconv.ParseUint(name[idx+1:], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("host name %q has no pod ordinal suffix: %w", name, err)
+		}
+		if nodes := uint64(maxWorkerId+1) * uint64(maxDatacenterId+1); ordinal >= nodes {
+			return 0, 0, fmt.Errorf("pod ordinal %d exceeds the %d available worker IDs", ordinal, nodes)
+		}
+		workerId, datacenterId := splitNodeId(ordinal, maxWorkerId, maxDatacenterId)
+		return workerId, datacenterId, nil
+	})
+}
+
+// splitNodeId splits a node number into a worker ID (lowest bits) and a datacenter ID (the bits above them).
+// Bits that don't fit into either are discarded.
+func splitNodeId(node uint64, maxWorkerId int64, maxDatacenterId int64) (int64, int64) {
+	workerId := int64(node & uint64(maxWorkerId))
+	datacenterId := int64((node >> bits.Len64(uint64(maxWorkerId))) & uint64(maxDatacenterId))
+	return workerId, datacenterId
+}
+
+// hostIPv4 returns the first non-loopback IPv4 address of the host.
+func hostIPv4() (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interface addresses: %w", err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, errors.New("no non-loopback IPv4 address found")
+}
+
+// hostMAC returns the MAC address of the first non-loopback interface that has one.
+func hostMAC() (net.HardwareAddr, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) > 0 {
+			return iface.HardwareAddr, nil
+		}
+	}
+	return nil, errors.New("no network interface with a MAC address found")
+}
+
+// releaseWorkerId closes a provider implementing io.Closer, such as LeaseWorkerIDProvider,
+// which stops its heartbeat and releases its lease.
+func (d *DistributeId) releaseWorkerId() {
+	if closer, ok := d.provider.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Warn("Failed to release snowflake worker ID", "error", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPWorkerIDProvider(t *testing.T) {
+	// 10.0.5.77 -> low 10 bits = 0b01_0100_1101 -> worker 13, datacenter 10
+	workerId, datacenterId, err := IPWorkerIDProvider(net.ParseIP("10.0.5.77")).WorkerID(context.Background(), 31, 31)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(13), workerId)
+	assert.Equal(t, int64(10), datacenterId)
+
+	_, _, err = IPWorkerIDProvider(net.ParseIP("fe80::1")).WorkerID(context.Background(), 31, 31)
+	assert.Error(t, err)
+}
+
+func TestMACWorkerIDProvider(t *testing.T) {
+	mac, _ := net.ParseMAC("00:16:3e:12:34:56")
+	provider := MACWorkerIDProvider(mac)
+
+	workerId, datacenterId, err := provider.WorkerID(context.Background(), 31, 31)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, workerId, int64(0))
+	assert.LessOrEqual(t, workerId, int64(31))
+	assert.GreaterOrEqual(t, datacenterId, int64(0))
+	assert.LessOrEqual(t, datacenterId, int64(31))
+
+	// The same MAC address always yields the same IDs.
+	workerId2, datacenterId2, err := provider.WorkerID(context.Background(), 31, 31)
+	assert.NoError(t, err)
+	assert.Equal(t, workerId, workerId2)
+	assert.Equal(t, datacenterId, datacenterId2)
+}
+
+func TestPodOrdinalWorkerIDProvider(t *testing.T) {
+	workerId, datacenterId, err := PodOrdinalWorkerIDProvider("orders-3").WorkerID(context.Background(), 31, 31)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), workerId)
+	assert.Equal(t, int64(0), datacenterId)
+
+	workerId, datacenterId, err = PodOrdinalWorkerIDProvider("order-api-70").WorkerID(context.Background(), 31, 31)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), workerId)
+	assert.Equal(t, int64(2), datacenterId)
+
+	_, _, err = PodOrdinalWorkerIDProvider("orders-1024").WorkerID(context.Background(), 31, 31)
+	assert.Error(t, err)
+
+	_, _, err = PodOrdinalWorkerIDProvider("orders").WorkerID(context.Background(), 31, 31)
+	assert.Error(t, err)
+
+	_, _, err = PodOrdinalWorkerIDProvider("orders-abc").WorkerID(context.Background(), 31, 31)
+	assert.Error(t, err)
+}
+
+// stubLease is a LeaseChecker whose state is controlled by the test.
+type stubLease struct {
+	err error
+}
+
+func (l *stubLease) CheckLease() error {
+	return l.err
+}
+
+func (l *stubLease) WorkerID(_ context.Context, _ int64, _ int64) (int64, int64, error) {
+	return 5, 6, nil
+}
+
+func TestNewWithProvider(t *testing.T) {
+	id, err := NewWithProvider(context.Background(), PodOrdinalWorkerIDProvider("orders-40"))
+	assert.NoError(t, err)
+	newID, err := id.NextId()
+	assert.NoError(t, err)
+	parts := id.Parse(newID)
+	assert.Equal(t, int64(8), parts.WorkerId)
+	assert.Equal(t, int64(1), parts.DatacenterId)
+
+	// Provider errors are returned.
+	_, err = NewWithProvider(context.Background(), PodOrdinalWorkerIDProvider("orders"))
+	assert.Error(t, err)
+
+	// Providers implementing LeaseChecker stop the generator when the lease is lost.
+	lease := &stubLease{}
+	id, err = NewWithProvider(context.Background(), lease)
+	assert.NoError(t, err)
+	_, err = id.NextId()
+	assert.NoError(t, err)
+
+	lease.err = ErrLeaseLost
+	_, err = id.NextId()
+	assert.True(t, errors.Is(err, ErrLeaseLost))
+}